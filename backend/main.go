@@ -1,36 +1,187 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
-// Global constants and variables
-var (
-	clientID     string
-	clientSecret string
-	// Pastikan redirectURI sesuai dengan yang didaftarkan di Strava App
-	redirectURI = "http://localhost:8080/strava-callback"
+// Config menyimpan konfigurasi server yang dimuat sekali saat startup (lihat loadConfig).
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	// Pastikan RedirectURI sesuai dengan yang didaftarkan di Strava App (STRAVA_REDIRECT_URI).
+	// Jika kosong, diturunkan dari host dan skema request login (lihat redirectURIFor).
+	RedirectURI string
 	// Sesuaikan dengan URL frontend Anda
-	frontendURL = "http://localhost:5173"
-	scope       = "read,activity:read_all"
+	FrontendURL string
+	Scope       string // STRAVA_SCOPE, bawaan "read,activity:read_all"
+	Port        string
+	// UsePKCE menambahkan code_challenge (S256) pada URL otorisasi dan code_verifier saat penukaran kode (STRAVA_PKCE)
+	UsePKCE bool
+
+	// Webhook Strava (opsional). Jika WebhookCallbackURL kosong, subscription tidak didaftarkan.
+	WebhookCallbackURL string // WEBHOOK_CALLBACK_URL, mis. https://contoh.com/api/webhook
+	WebhookVerifyToken string // WEBHOOK_VERIFY_TOKEN, dicocokkan saat validasi subscription
+}
+
+// Server menampung konfigurasi dan menyediakan handler HTTP sebagai method,
+// sehingga handler dapat diuji dengan konfigurasi yang berbeda.
+type Server struct {
+	cfg         Config
+	clock       Clock
+	oauthStates *oauthStateStore
+	// webhookSubscriptionID adalah ID subscription webhook milik aplikasi ini (0 jika belum diketahui)
+	webhookSubscriptionID atomic.Int64
+}
+
+func newServer(cfg Config) *Server {
+	return &Server{cfg: cfg, clock: realClock{}, oauthStates: newOAuthStateStore()}
+}
+
+// Clock menyediakan waktu saat ini. Logika token dan statistik memakai Clock, bukan time.Now,
+// agar hasilnya dapat ditentukan dalam pengujian.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock adalah Clock produksi yang memakai jam sistem.
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// Satuan yang didukung oleh parameter ?units= pada endpoint statistik
+const (
+	unitMetric    = "metric"
+	unitImperial  = "imperial"
+	metersPerMile = 1609.344
+)
+
+// Lokasi file data. Diturunkan dari DATA_DIR saat startup (lihat setDataDir); bawaan folder "data".
+var (
+	dataDir       = defaultDataDir
+	dataFilePath  = filepath.Join(defaultDataDir, "strava_activities.json.gz")
+	tokenFilePath = filepath.Join(defaultDataDir, "strava_token.json") // File baru untuk menyimpan token
+	goalsFilePath = filepath.Join(defaultDataDir, "goals.json")
+	splitsDir     = filepath.Join(defaultDataDir, "splits")  // Cache split per aktivitas: <id>.json
+	streamsDir    = filepath.Join(defaultDataDir, "streams") // Cache stream kecepatan per aktivitas: <id>.json
+	gearFilePath  = filepath.Join(defaultDataDir, "gear.json")
+	// Override pemetaan tipe Strava -> kategori, mis. {"Workout": "RunWalkHike"}
+	classificationFilePath = filepath.Join(defaultDataDir, "classification.json")
+	cacheMetaFilePath      = filepath.Join(defaultDataDir, "cache_meta.json") // Rentang tanggal yang tercakup cache aktivitas
+	// Cache lama tanpa kompresi; tetap dibaca dan dihapus setelah cache gzip pertama ditulis
+	legacyDataFilePath = filepath.Join(defaultDataDir, "strava_activities.json")
+	// ID subscription webhook terakhir yang terdaftar; event dengan subscription_id lain ditolak
+	webhookFilePath = filepath.Join(defaultDataDir, "webhook_subscription.json")
+)
+
+const defaultDataDir = "data"
+
+// setDataDir mengatur direktori data dan menurunkan ulang semua path file di dalamnya.
+// Harus dipanggil sebelum file apa pun dibaca (mis. sebelum loadToken).
+func setDataDir(dir string) {
+	dataDir = dir
+	dataFilePath = filepath.Join(dir, "strava_activities.json.gz")
+	legacyDataFilePath = filepath.Join(dir, "strava_activities.json")
+	tokenFilePath = filepath.Join(dir, "strava_token.json")
+	goalsFilePath = filepath.Join(dir, "goals.json")
+	splitsDir = filepath.Join(dir, "splits")
+	streamsDir = filepath.Join(dir, "streams")
+	gearFilePath = filepath.Join(dir, "gear.json")
+	classificationFilePath = filepath.Join(dir, "classification.json")
+	cacheMetaFilePath = filepath.Join(dir, "cache_meta.json")
+	webhookFilePath = filepath.Join(dir, "webhook_subscription.json")
+}
+
+const (
+	// Refresh token dicoba hingga 3 kali (backoff 1s, 2s) dalam batas waktu total 30 detik
+	tokenRefreshAttempts       = 3
+	tokenRefreshInitialBackoff = 1 * time.Second
+	tokenRefreshTimeout        = 30 * time.Second
+	// Waktu tunggu maksimal bagi request yang sedang berjalan (mis. sinkronisasi) saat shutdown
+	shutdownGracePeriod = 30 * time.Second
+)
+
+// maxRateLimitRetries adalah jumlah maksimal percobaan ulang saat Strava merespons 429.
+const maxRateLimitRetries = 3
+
+// rateLimitRetryDelay adalah jeda sebelum mencoba ulang setelah respons 429 (STRAVA_RATE_LIMIT_RETRY_DELAY).
+// Nilai 0 berarti menunggu hingga jendela 15 menit Strava berikutnya dimulai.
+var rateLimitRetryDelay time.Duration
+
+// activitiesFileMutex menyerialkan operasi read-modify-write pada file cache aktivitas
+// (sinkronisasi inkremental dan event webhook) agar perubahan tidak saling menimpa.
+var activitiesFileMutex sync.Mutex
+
+// cacheTTL adalah umur maksimal cache aktivitas (berdasarkan mtime file) sebelum diperbarui
+// otomatis oleh /api/activities (CACHE_TTL, bawaan 6 jam). Nilai 0 menonaktifkan pembaruan otomatis.
+var cacheTTL = defaultCacheTTL
+
+const defaultCacheTTL = 6 * time.Hour
+
+// autoSyncInterval adalah jeda sinkronisasi inkremental otomatis di latar belakang (AUTO_SYNC_INTERVAL).
+// Nilai 0 (bawaan) menonaktifkannya, sehingga sinkronisasi hanya terjadi atas permintaan.
+var autoSyncInterval time.Duration
+
+// minAutoSyncInterval membatasi AUTO_SYNC_INTERVAL agar sinkronisasi otomatis tidak menghabiskan
+// batas harian Strava (satu sinkronisasi inkremental biasanya hanya satu request).
+const minAutoSyncInterval = 15 * time.Minute
+
+// tokenTTLMargin adalah margin sebelum token benar-benar kedaluwarsa; token di dalam margin ini
+// sudah di-refresh (TOKEN_TTL_MARGIN_SECONDS, bawaan 60 detik).
+var tokenTTLMargin = defaultTokenTTLMargin
+
+const defaultTokenTTLMargin = 60 * time.Second
+
+// stravaClient dipakai untuk semua request keluar ke Strava (OAuth, API, webhook), dibuat ulang
+// saat startup dengan STRAVA_CONNECT_TIMEOUT dan STRAVA_READ_TIMEOUT.
+var stravaClient = newStravaClient(defaultStravaConnectTimeout, defaultStravaReadTimeout)
+
+const (
+	defaultStravaConnectTimeout = 10 * time.Second // Koneksi TCP + handshake TLS
+	defaultStravaReadTimeout    = 60 * time.Second // Total satu request, termasuk membaca body
+)
+
+// Paginasi sinkronisasi aktivitas: jumlah aktivitas per halaman (STRAVA_PER_PAGE, dipotong ke
+// maksimal Strava 200) dan batas jumlah halaman (STRAVA_MAX_PAGES) sebagai pengaman jika
+// kondisi berhenti tidak pernah terpenuhi.
+var (
+	stravaPerPage  = maxStravaPerPage
+	stravaMaxPages = defaultStravaMaxPages
 )
 
 const (
-	dataFilePath   = "data/strava_activities.json"
-	tokenFilePath  = "data/strava_token.json" // File baru untuk menyimpan token
-	dataDir        = "data"
-	tokenTTLMargin = 60 * time.Second // Margin 60 detik sebelum token benar-benar kedaluwarsa
+	maxStravaPerPage      = 200
+	defaultStravaMaxPages = 1000 // 200.000 aktivitas dengan per_page 200
 )
 
 // --- Token Management Structures ---
@@ -39,28 +190,108 @@ const (
 type TokenData struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
-	ExpiresAt    int64  `json:"expires_at"` // Unix timestamp
+	ExpiresAt    int64  `json:"expires_at"`           // Unix timestamp
+	AthleteID    int64  `json:"athlete_id,omitempty"` // Dari respons penukaran kode; 0 untuk file token lama
 }
 
+// PaceStat: Jarak per zona pace. Kunci JSON sama dengan PaceZone.Key; label tampilan tersedia di /api/pace-zones.
 type PaceStat struct {
-	// JSON tags disederhanakan agar sesuai dengan PaceStat di frontend (Red, Orange, Yellow, Green)
-	Red    float64 `json:"Red"`
-	Orange float64 `json:"Orange"`
-	Yellow float64 `json:"Yellow"`
-	Green  float64 `json:"Green"`
+	Red    float64 `json:"red"`
+	Orange float64 `json:"orange"`
+	Yellow float64 `json:"yellow"`
+	Green  float64 `json:"green"`
+}
+
+// PaceZoneConfig menyimpan batas bawah kecepatan (m/s) untuk setiap zona pace.
+// Nilai harus menurun secara ketat (Red > Orange > Yellow); kecepatan di bawah
+// Yellow otomatis masuk zona Green.
+type PaceZoneConfig struct {
+	Red    float64
+	Orange float64
+	Yellow float64
+}
+
+// defaultPaceZones adalah batas bawaan yang dipakai jika variabel lingkungan tidak diisi.
+var defaultPaceZones = PaceZoneConfig{
+	Red:    4.8, // Pace < 3:28 /km
+	Orange: 3.8, // Pace 3:28 - 4:23 /km
+	Yellow: 3.0, // Pace 4:23 - 5:33 /km
+}
+
+// paceZones adalah konfigurasi zona pace aktif, dimuat sekali saat startup.
+var paceZones = defaultPaceZones
+
+// defaultWalkPaceZones adalah batas bawaan untuk jalan, hiking, dan trail run.
+var defaultWalkPaceZones = PaceZoneConfig{
+	Red:    2.2, // Pace < 7:35 /km
+	Orange: 1.8, // Pace 7:35 - 9:16 /km
+	Yellow: 1.3, // Pace 9:16 - 12:49 /km
+}
+
+// walkPaceZones adalah konfigurasi zona pace jalan/hiking aktif, dimuat sekali saat startup.
+var walkPaceZones = defaultWalkPaceZones
+
+// MaxSpeedConfig menyimpan batas kecepatan rata-rata (m/s) yang masih wajar per kategori aktivitas.
+// Aktivitas di atas batas dianggap glitch GPS dan tidak dihitung. Nilai 0 menonaktifkan filter kategori tersebut.
+type MaxSpeedConfig struct {
+	RunWalkHike float64
+	Bike        float64
+	Other       float64
+}
+
+// defaultMaxSpeeds adalah batas bawaan jika MAX_SPEED_* tidak diisi.
+var defaultMaxSpeeds = MaxSpeedConfig{
+	RunWalkHike: 12, // ~1:23 /km, di atas rekor dunia sprint
+	Bike:        25, // 90 km/jam
+	Other:       0,  // Kategori campuran (ski, dayung, dll.); tidak difilter
 }
 
+// maxSpeeds adalah batas kecepatan aktif, dimuat sekali saat startup.
+var maxSpeeds = defaultMaxSpeeds
+
+// defaultHRZoneBounds adalah batas bawah (bpm) zona 2 s.d. zona 5; di bawah batas pertama adalah zona 1.
+var defaultHRZoneBounds = []float64{120, 140, 155, 170}
+
+// hrZoneBounds adalah batas zona detak jantung aktif (HR_ZONES), dimuat sekali saat startup.
+var hrZoneBounds = defaultHRZoneBounds
+
 // WeeklySummaryStats: Struktur untuk menampung ringkasan statistik
 type WeeklySummaryStats struct {
-	TotalDistanceKM float64 `json:"total_distance_km"`
+	TotalDistance   float64 `json:"total_distance"`            // Dalam satuan units: km atau mil
 	TotalMovingTime float64 `json:"total_moving_time_seconds"` // Dalam detik
-	AveragePace     float64 `json:"average_pace_sec_per_m"`    // Detik per meter (Global Pace)
+	AveragePace     float64 `json:"average_pace"`              // Dalam satuan units: detik/meter atau menit/mil
+	// Kunci lama untuk frontend; selalu metrik apa pun nilai units
+	TotalDistanceKM    float64 `json:"total_distance_km"`
+	AveragePaceSecPerM float64 `json:"average_pace_sec_per_m"` // Detik per meter (Global Pace)
 }
 
 // GlobalWeeklyData: Struktur Gabungan untuk respons ke frontend
 type GlobalWeeklyData struct {
 	PaceData WeeklyPaceData     `json:"pace_data"`
 	Summary  WeeklySummaryStats `json:"summary"`
+	Units    string             `json:"units"` // metric (km, detik/meter) atau imperial (mil, menit/mil)
+	// Previous dan Delta hanya diisi dengan ?compare=true
+	Previous *WeeklyPeriodData `json:"previous,omitempty"`
+	Delta    *WeeklyPaceDelta  `json:"delta,omitempty"`
+	// CoverageIncomplete bernilai true jika rentang keluar dari [CoveredFrom, CoveredUntil) (RFC3339),
+	// yaitu rentang yang sudah disinkronkan ke cache; statistik di luarnya mungkin kurang.
+	CoverageIncomplete bool   `json:"coverage_incomplete,omitempty"`
+	CoveredFrom        string `json:"covered_from,omitempty"`
+	CoveredUntil       string `json:"covered_until,omitempty"`
+}
+
+// WeeklyPeriodData: Data harian dan ringkasan untuk 7 hari sebelum startDate (pembanding)
+type WeeklyPeriodData struct {
+	StartDate string             `json:"start_date"` // YYYY-MM-DD
+	EndDate   string             `json:"end_date"`   // YYYY-MM-DD
+	PaceData  WeeklyPaceData     `json:"pace_data"`
+	Summary   WeeklySummaryStats `json:"summary"`
+}
+
+// WeeklyPaceDelta: Selisih total jarak per zona (periode ini dikurangi periode sebelumnya)
+type WeeklyPaceDelta struct {
+	Zones PaceStat `json:"zones"`
+	Total float64  `json:"total"` // Jumlah selisih semua zona
 }
 
 // calculateWeeklySummaryStats menghitung total jarak, waktu, dan pace rata-rata untuk aktivitas lari.
@@ -73,11 +304,11 @@ func calculateWeeklySummaryStats(activities []StravaActivity, startDate, endDate
 	nextDayStart := endDate.AddDate(0, 0, 1)
 
 	for _, activity := range activities {
-		if activity.Type != "Run" {
+		if !isRunType(activity.Type) {
 			continue // Hanya hitung aktivitas lari
 		}
 
-		activityTime, err := time.Parse(time.RFC3339, activity.StartDateLocal)
+		activityTime, err := parseLocalWallTime(activity.StartDateLocal, startDate.Location())
 		if err != nil {
 			continue
 		}
@@ -92,18 +323,30 @@ func calculateWeeklySummaryStats(activities []StravaActivity, startDate, endDate
 	}
 
 	summary := WeeklySummaryStats{
-		TotalDistanceKM: totalDistance / 1000.0,
+		TotalDistance:   totalDistance / 1000.0,
 		TotalMovingTime: totalMovingTime,
+		TotalDistanceKM: totalDistance / 1000.0,
 	}
 
 	if totalDistance > 0 {
 		// Pace rata-rata = Total Waktu (detik) / Total Jarak (meter)
 		summary.AveragePace = totalMovingTime / totalDistance
+		summary.AveragePaceSecPerM = summary.AveragePace
 	}
 
 	return summary
 }
 
+// DailySportStats: Total jarak (meter) per kategori untuk satu hari
+type DailySportStats struct {
+	RunWalkHike float64 `json:"run_walk_hike"`
+	Bike        float64 `json:"bike"`
+	Other       float64 `json:"other"`
+}
+
+// WeeklyDistanceData: Kunci: Tanggal (string YYYY-MM-DD), Nilai: DailySportStats untuk hari itu
+type WeeklyDistanceData map[string]DailySportStats
+
 // WeeklyPaceData: Struktur baru untuk menampung data harian
 // Kunci: Tanggal (string YYYY-MM-DD), Nilai: PaceStat untuk hari itu
 type WeeklyPaceData map[string]PaceStat
@@ -112,31 +355,308 @@ type WeeklyPaceData map[string]PaceStat
 var (
 	currentTokens TokenData
 	tokenMutex    sync.Mutex // Untuk mencegah race condition saat mengakses token
+	// refreshMutex memastikan hanya satu refresh token yang berjalan pada satu waktu.
+	// Urutan lock: refreshMutex selalu diambil sebelum tokenMutex, tidak pernah sebaliknya.
+	refreshMutex sync.Mutex
+	// tokensLoaded bernilai true setelah loadToken selesai dijalankan (dipakai oleh /readyz)
+	tokensLoaded atomic.Bool
 )
 
+// encryptedTokenFile adalah format file token saat TOKEN_ENCRYPTION_KEY diisi.
+// Field []byte otomatis di-encode sebagai base64 oleh encoding/json.
+type encryptedTokenFile struct {
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"` // TokenData (JSON) yang dienkripsi dengan AES-256-GCM
+}
+
+// tokenEncryptionKey adalah kunci AES-256 yang diturunkan dari TOKEN_ENCRYPTION_KEY.
+// Bernilai nil jika variabel tidak diisi (token disimpan sebagai teks biasa).
+var tokenEncryptionKey []byte
+
+// tokenFileMode adalah mode file untuk strava_token.json (TOKEN_FILE_MODE, oktal, bawaan 0600).
+// File token berisi access token dan refresh token yang memberi akses penuh ke akun Strava
+// pengguna, sehingga di server multi-pengguna file ini tidak boleh dapat dibaca pengguna lain.
+// Bawaannya hanya pemilik proses yang bisa membaca/menulis; longgarkan hanya jika benar-benar perlu.
+var tokenFileMode os.FileMode = defaultTokenFileMode
+
+const defaultTokenFileMode os.FileMode = 0600
+
+// loadTokenFileMode membaca TOKEN_FILE_MODE sebagai bilangan oktal (mis. "0600" atau "640").
+// Pemilik wajib dapat membaca dan menulis file, karena token ditulis ulang setiap refresh.
+func loadTokenFileMode() (os.FileMode, error) {
+	raw := strings.TrimSpace(os.Getenv("TOKEN_FILE_MODE"))
+	if raw == "" {
+		return defaultTokenFileMode, nil
+	}
+	mode, err := strconv.ParseUint(raw, 8, 32)
+	if err != nil || mode > 0777 {
+		return defaultTokenFileMode, fmt.Errorf("TOKEN_FILE_MODE harus mode file oktal antara 0000 dan 0777 (%q)", raw)
+	}
+	if mode&0600 != 0600 {
+		return defaultTokenFileMode, fmt.Errorf("TOKEN_FILE_MODE harus memberi izin baca/tulis kepada pemilik (%q)", raw)
+	}
+	if mode&0077 != 0 {
+		slog.Warn("TOKEN_FILE_MODE mengizinkan pengguna lain mengakses file token", "mode", fmt.Sprintf("%#o", mode))
+	}
+	return os.FileMode(mode), nil
+}
+
 // StravaTokenResponse merepresentasikan struktur respons token dari Strava (digunakan saat pertukaran kode/refresh).
 type StravaTokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	ExpiresAt    int64  `json:"expires_at"` // Unix timestamp
+	// Athlete hanya disertakan pada penukaran kode otorisasi, tidak pada refresh token.
+	Athlete struct {
+		ID int64 `json:"id"`
+	} `json:"athlete"`
 }
 
 // MinimalActivityData (struktur yang sama)
 type MinimalActivityData struct {
-	StartDate  string  `json:"start_date"`
-	Distance   float64 `json:"distance"`    // meter
-	MovingTime float64 `json:"moving_time"` // detik
-	Type       string  `json:"type"`
+	StartDate          string  `json:"start_date"`
+	StartDateLocal     string  `json:"start_date_local"`     // Waktu lokal di lokasi aktivitas (sufiks Z dari Strava)
+	Distance           float64 `json:"distance"`             // meter
+	MovingTime         float64 `json:"moving_time"`          // detik
+	TotalElevationGain float64 `json:"total_elevation_gain"` // meter
+	Type               string  `json:"type"`
+	KudosCount         int     `json:"kudos_count"`
+	AchievementCount   int     `json:"achievement_count"`
+}
+
+// localMonth mengembalikan bulan (YYYY-MM) aktivitas menurut start_date_local, sama dengan
+// penanggalan harian endpoint mingguan. Mengembalikan false jika tanggal tidak dapat diurai.
+func (a MinimalActivityData) localMonth() (string, bool) {
+	t, ok := localStartTime(a.StartDate, a.StartDateLocal)
+	if !ok {
+		return "", false
+	}
+	return t.Format("2006-01"), true
+}
+
+// localStartTime mengembalikan waktu mulai aktivitas menurut jam dinding atlet (start_date_local).
+// Jika start_date_local tidak ada, start_date dikonversi ke APP_TIMEZONE.
+// Mengembalikan false jika tanggal tidak dapat diurai.
+func localStartTime(startDate, startDateLocal string) (time.Time, bool) {
+	if startDateLocal != "" {
+		t, err := parseLocalWallTime(startDateLocal, time.UTC)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+	t, err := time.Parse(time.RFC3339, startDate)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.In(appLocation), true
 }
 
 // MonthlySportStats (struktur yang sama)
 type MonthlySportStats struct {
-	MonthYear   string  `json:"month_year"` // Format: YYYY-MM
+	MonthYear          string  `json:"month_year"` // Format: YYYY-MM
+	RunWalkHike        float64 `json:"run_walk_hike"`
+	Bike               float64 `json:"bike"`
+	Other              float64 `json:"other"`
+	TotalElevationGain float64 `json:"total_elevation_gain"` // meter, semua kategori
+	// OtherByType merinci jarak Other per tipe Strava mentah (mis. Swim, Yoga); hanya dikirim dengan ?expand_other=true
+	OtherByType map[string]float64 `json:"other_by_type,omitempty"`
+}
+
+// YearlySportStats: Ringkasan jarak per tahun kalender
+type YearlySportStats struct {
+	Year        string  `json:"year"` // Format: YYYY
+	RunWalkHike float64 `json:"run_walk_hike"`
+	Bike        float64 `json:"bike"`
+	Other       float64 `json:"other"`
+}
+
+// MonthlySocialStats: Total kudos dan achievement dari aktivitas dalam satu bulan
+type MonthlySocialStats struct {
+	MonthYear        string `json:"month_year"` // Format: YYYY-MM
+	KudosCount       int    `json:"kudos_count"`
+	AchievementCount int    `json:"achievement_count"`
+	ActivityCount    int    `json:"activity_count"`
+}
+
+// DataValidationReport: Hasil pemeriksaan setiap record di cache aktivitas
+type DataValidationReport struct {
+	Total           int               `json:"total"`
+	Valid           int               `json:"valid"`
+	Skipped         int               `json:"skipped"`
+	SkippedByReason map[string]int    `json:"skipped_by_reason"`
+	SkippedSamples  []SkippedActivity `json:"skipped_samples"` // Maksimal maxSkippedSamples entri
+}
+
+// SkippedActivity: Record yang dilewati oleh perhitungan statistik beserta alasannya
+type SkippedActivity struct {
+	ID     interface{} `json:"id"`
+	Name   string      `json:"name"`
+	Reason string      `json:"reason"`
+}
+
+// maxSkippedSamples membatasi jumlah contoh record yang dilewati pada laporan validasi.
+const maxSkippedSamples = 20
+
+// ActivityRecord: Satu rekor pribadi beserta aktivitas asalnya
+type ActivityRecord struct {
+	ActivityID int64   `json:"activity_id"`
+	Name       string  `json:"name"`
+	StartDate  string  `json:"start_date_local"`
+	Value      float64 `json:"value"`
+}
+
+// PersonalRecords: Rekor pribadi untuk aktivitas lari. Field bernilai null jika belum ada data.
+type PersonalRecords struct {
+	FastestPace       *ActivityRecord `json:"fastest_pace"`        // value: kecepatan rata-rata (m/s), lari >= 1 km
+	LongestDistance   *ActivityRecord `json:"longest_distance"`    // value: jarak (meter)
+	LongestMovingTime *ActivityRecord `json:"longest_moving_time"` // value: waktu bergerak (detik)
+}
+
+// MonthlyHRStats: Total waktu bergerak lari (detik) per zona detak jantung dalam satu bulan.
+// ZoneSeconds[0] adalah zona 1 (di bawah batas pertama), indeks terakhir adalah zona tertinggi.
+type MonthlyHRStats struct {
+	MonthYear   string    `json:"month_year"` // Format: YYYY-MM
+	ZoneSeconds []float64 `json:"zone_seconds"`
+}
+
+// EfficiencyStat: Total waktu bergerak dan waktu total (elapsed) beserta rasionya
+type EfficiencyStat struct {
+	MovingTime  float64 `json:"moving_time_seconds"`
+	ElapsedTime float64 `json:"elapsed_time_seconds"`
+	Ratio       float64 `json:"ratio"` // moving/elapsed (0-1); 0 jika tidak ada aktivitas
+}
+
+// ClimbStat: Total elevasi dan laju tanjakan satu kategori (hanya aktivitas dengan data elevasi)
+type ClimbStat struct {
+	ElevationGain float64 `json:"elevation_gain"` // meter
+	Distance      float64 `json:"distance"`       // meter
+	ClimbRate     float64 `json:"climb_rate"`     // meter naik per km; 0 jika tidak ada aktivitas
+}
+
+// MonthlyClimbStats: Elevasi per kategori dalam satu bulan
+type MonthlyClimbStats struct {
+	MonthYear   string    `json:"month_year"` // Format: YYYY-MM
+	RunWalkHike ClimbStat `json:"run_walk_hike"`
+	Bike        ClimbStat `json:"bike"`
+}
+
+// MonthlyEfficiencyStats: Rasio waktu bergerak terhadap waktu total per kategori dalam satu bulan
+type MonthlyEfficiencyStats struct {
+	MonthYear   string         `json:"month_year"` // Format: YYYY-MM
+	RunWalkHike EfficiencyStat `json:"run_walk_hike"`
+	Bike        EfficiencyStat `json:"bike"`
+	Other       EfficiencyStat `json:"other"`
+}
+
+// GearStat: Total jarak dan jumlah aktivitas per gear (mis. sepatu)
+type GearStat struct {
+	GearID        string  `json:"gear_id"` // "unassigned" untuk aktivitas tanpa gear
+	Name          string  `json:"name"`    // Kosong jika nama gear belum diketahui
+	TotalDistance float64 `json:"total_distance"`
+	ActivityCount int     `json:"activity_count"`
+}
+
+// unassignedGearID mengelompokkan aktivitas yang tidak memakai gear.
+const unassignedGearID = "unassigned"
+
+// LifetimeSummary: Total sepanjang masa dari seluruh aktivitas di cache lokal
+type LifetimeSummary struct {
+	RunWalkHike       float64 `json:"run_walk_hike"` // meter
+	Bike              float64 `json:"bike"`          // meter
+	Other             float64 `json:"other"`         // meter
+	TotalActivities   int     `json:"total_activities"`
+	TotalMovingTime   float64 `json:"total_moving_time_seconds"`
+	FirstActivityDate string  `json:"first_activity_date"` // RFC3339 waktu lokal (start_date_local), kosong jika belum ada aktivitas
+	LastActivityDate  string  `json:"last_activity_date"`  // RFC3339 waktu lokal (start_date_local), kosong jika belum ada aktivitas
+}
+
+// RollingWindowStats: Total jarak per kategori dalam N hari terakhir (termasuk hari ini)
+type RollingWindowStats struct {
+	Days        int     `json:"days"`
 	RunWalkHike float64 `json:"run_walk_hike"`
 	Bike        float64 `json:"bike"`
 	Other       float64 `json:"other"`
 }
 
+// RollingStats: Jarak rolling 7/30/90 hari per tanggal acuan
+type RollingStats struct {
+	AsOf    string               `json:"as_of"` // Format: YYYY-MM-DD
+	Windows []RollingWindowStats `json:"windows"`
+}
+
+// PaceBucket: Jumlah lari dan total jarak dengan pace rata-rata dalam [MinPace, MaxPace)
+type PaceBucket struct {
+	MinPace       float64 `json:"min_pace_sec_per_km"`
+	MaxPace       float64 `json:"max_pace_sec_per_km"`
+	Count         int     `json:"count"`
+	TotalDistance float64 `json:"total_distance"` // meter
+}
+
+// paceBucketWidth adalah lebar satu bucket /api/pace-distribution (detik per km).
+const paceBucketWidth = 15.0
+
+// AvailablePeriods: Bulan dan tahun yang memiliki aktivitas di cache, untuk pilihan dropdown frontend
+type AvailablePeriods struct {
+	Months []string `json:"months"` // YYYY-MM, urut naik
+	Years  []string `json:"years"`  // YYYY, urut naik
+}
+
+// WeeklyMileage: Total jarak lari satu minggu
+type WeeklyMileage struct {
+	WeekStart string  `json:"week_start"` // YYYY-MM-DD, hari pertama minggu (WEEK_START)
+	Distance  float64 `json:"distance"`
+}
+
+// AvgWeeklyMileage: Rata-rata jarak lari mingguan selama N minggu penuh terakhir
+type AvgWeeklyMileage struct {
+	Weeks           int             `json:"weeks"`
+	AverageDistance float64         `json:"average_distance"`
+	WeeklyTotals    []WeeklyMileage `json:"weekly_totals"` // Urut dari minggu terlama
+	Units           string          `json:"units"`
+}
+
+// Batas ?weeks pada /api/avg-weekly-mileage
+const (
+	defaultMileageWeeks = 12
+	maxMileageWeeks     = 52
+)
+
+// StreakStats: Rangkaian hari aktif berturut-turut per tanggal acuan
+type StreakStats struct {
+	AsOf               string `json:"as_of"` // Format: YYYY-MM-DD
+	CurrentStreak      int    `json:"current_streak_days"`
+	LongestStreak      int    `json:"longest_streak_days"`
+	LongestStreakStart string `json:"longest_streak_start,omitempty"` // YYYY-MM-DD, kosong jika belum ada aktivitas
+	LongestStreakEnd   string `json:"longest_streak_end,omitempty"`
+	ActiveDays         int    `json:"active_days"` // Jumlah tanggal unik dengan minimal satu aktivitas
+}
+
+// WeekdayStat: Jumlah aktivitas dan total jarak untuk satu hari dalam seminggu
+type WeekdayStat struct {
+	Weekday       string  `json:"weekday"` // Monday ... Sunday
+	Count         int     `json:"count"`
+	TotalDistance float64 `json:"total_distance"` // meter (mil dengan ?units=imperial)
+}
+
+// YTDPoint: Jarak kumulatif sejak 1 Januari hingga akhir satu tanggal
+type YTDPoint struct {
+	Date     string  `json:"date"`     // Format: YYYY-MM-DD
+	Distance float64 `json:"distance"` // meter (mil dengan ?units=imperial), kumulatif
+}
+
+// YTDComparison: Seri jarak kumulatif harian tahun ini dan tahun lalu hingga tanggal yang sama
+type YTDComparison struct {
+	AsOf         string     `json:"as_of"` // Format: YYYY-MM-DD
+	CurrentYear  int        `json:"current_year"`
+	PreviousYear int        `json:"previous_year"`
+	Current      []YTDPoint `json:"current"`  // 1 Januari hingga as_of
+	Previous     []YTDPoint `json:"previous"` // 1 Januari tahun lalu hingga tanggal yang sama tahun lalu
+}
+
+// rollingWindowDays adalah panjang jendela (hari) yang dihitung oleh /api/rolling-stats.
+var rollingWindowDays = []int{7, 30, 90}
+
 type StravaActivity struct {
 	ID             int64   `json:"id"`
 	Name           string  `json:"name"`
@@ -146,9 +666,54 @@ type StravaActivity struct {
 	Type           string  `json:"type"`
 	StartDate      string  `json:"start_date"`       // UTC time (RFC3339)
 	StartDateLocal string  `json:"start_date_local"` // Local time (RFC3339)
+
+	TotalElevationGain *float64 `json:"total_elevation_gain"` // meter, nil jika Strava tidak mengirim data elevasi
+	AverageHeartrate   float64  `json:"average_heartrate"`    // bpm, 0 jika tidak ada data HR
+	MaxHeartrate       float64  `json:"max_heartrate"`        // bpm, 0 jika tidak ada data HR
+	KudosCount         int      `json:"kudos_count"`
+	AchievementCount   int      `json:"achievement_count"`
+	Private            bool     `json:"private"`
+	Commute            bool     `json:"commute"` // Ditandai sebagai perjalanan commute di Strava
+	GearID             string   `json:"gear_id"` // Kosong jika aktivitas tidak memakai gear
 	// Tambahkan field lain yang mungkin Anda gunakan
 }
 
+// TypedActivity: Aktivitas bertipe untuk /api/activities/typed, beserta nilai turunan
+type TypedActivity struct {
+	StravaActivity
+	AvgSpeedMPS  *float64 `json:"avg_speed_mps"`       // null jika jarak atau waktu bergerak nol
+	PaceMinPerKm *float64 `json:"pace_min_per_km"`     // null jika jarak atau waktu bergerak nol
+	PaceZone     string   `json:"pace_zone,omitempty"` // red, orange, yellow, atau green; hanya untuk lari/jalan/hiking
+}
+
+// elevationGain mengembalikan total elevasi (meter), atau 0 jika data elevasi tidak ada.
+func (a StravaActivity) elevationGain() float64 {
+	if a.TotalElevationGain == nil {
+		return 0
+	}
+	return *a.TotalElevationGain
+}
+
+// StatusResponse: Respons GET /api/status. Nama field JSON adalah kontrak dengan frontend, jangan diubah.
+type StatusResponse struct {
+	Status          string `json:"status"`
+	DataFile        string `json:"data_file"`
+	FileStatus      string `json:"file_status"`       // OK, Missing, atau Error: <pesan>
+	CacheAgeSeconds *int64 `json:"cache_age_seconds"` // null jika file data tidak ada
+	CacheUpdatedAt  string `json:"cache_updated_at"`  // RFC822, atau N/A
+	CacheStale      bool   `json:"cache_stale"`
+	TokenValid      bool   `json:"token_status"`
+	TokenExpires    string `json:"token_expires"` // RFC822, atau N/A
+	HasRefreshToken bool   `json:"refresh_token"` // Hanya untuk debug, cek apakah refresh token ada
+}
+
+// PaceTrendPoint: Pace rata-rata satu bulan untuk aktivitas yang jatuh di satu zona pace
+type PaceTrendPoint struct {
+	MonthYear     string  `json:"month_year"`     // Format: YYYY-MM
+	Pace          float64 `json:"pace"`           // detik/meter (menit/mil dengan ?units=imperial), total waktu / total jarak
+	ActivityCount int     `json:"activity_count"` // Jumlah aktivitas di zona tersebut pada bulan ini
+}
+
 // MonthlyPaceStats (struktur yang sama)
 type MonthlyPaceStats struct {
 	MonthYear string `json:"month_year"` // Format: YYYY-MM
@@ -160,877 +725,6839 @@ type MonthlyPaceStats struct {
 	BikeDistance        float64 `json:"-"`
 	OtherTime           float64 `json:"-"`
 	OtherDistance       float64 `json:"-"`
+	SwimTime            float64 `json:"-"`
+	SwimDistance        float64 `json:"-"`
 
 	// Pace Rata-rata yang akan dikirim ke Frontend (detik/meter)
 	RunWalkHikePace float64 `json:"run_walk_hike_pace"` // detik/meter
 	BikePace        float64 `json:"bike_pace"`          // detik/meter
 	OtherPace       float64 `json:"other_pace"`         // detik/meter
+	SwimPace        float64 `json:"swim_pace"`          // detik/100 meter (renang tidak ikut dihitung di other_pace)
+}
+
+// CategoryMonthlyStats: Jarak dan pace satu kategori dalam satu bulan
+type CategoryMonthlyStats struct {
+	MonthYear string  `json:"month_year"` // Format: YYYY-MM
+	Distance  float64 `json:"distance"`   // meter (mil dengan ?units=imperial)
+	Pace      float64 `json:"pace"`       // detik/meter (menit/mil dengan ?units=imperial), 0 jika tidak ada data
+}
+
+// CategoryStats: Respons /api/stats/category/:category
+type CategoryStats struct {
+	Category string                 `json:"category"` // run_walk_hike, bike, atau other
+	Months   []CategoryMonthlyStats `json:"months"`
+}
+
+// statsCategoryParams memetakan parameter :category pada /api/stats/category ke kategori internal.
+var statsCategoryParams = map[string]string{
+	"run_walk_hike": "RunWalkHike",
+	"bike":          "Bike",
+	"other":         "Other",
 }
 
 func main() {
 	// 1. Muat variabel lingkungan dari file .env
-	err := godotenv.Load()
-	if err != nil {
-		fmt.Println("Peringatan: Tidak dapat memuat file .env. Menggunakan Environment Variables Sistem.")
+	envErr := godotenv.Load()
+
+	// Logger JSON terstruktur; level diatur lewat LOG_LEVEL (debug, info, warn, error)
+	setupLogger()
+	if envErr != nil {
+		slog.Warn("Tidak dapat memuat file .env. Menggunakan Environment Variables Sistem.", "error", envErr)
 	}
 
 	// Ambil nilai dari environment variables
-	clientID = os.Getenv("STRAVA_CLIENT_ID")
-	clientSecret = os.Getenv("STRAVA_CLIENT_SECRET")
-	port := os.Getenv("BACKEND_PORT")
-	if port == "" {
-		port = "8080" // Default port
+	cfg, err := loadConfig()
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid. Pastikan .env sudah benar.", "error", err)
+		os.Exit(1)
 	}
 
-	if clientID == "" || clientSecret == "" {
-		fmt.Println("Error: STRAVA_CLIENT_ID atau STRAVA_CLIENT_SECRET tidak ditemukan. Pastikan .env sudah benar.")
+	// Muat batas zona pace (PACE_ZONE_RED, PACE_ZONE_ORANGE, PACE_ZONE_YELLOW)
+	zones, err := loadPaceZoneConfig("PACE_ZONE", defaultPaceZones)
+	if err != nil {
+		slog.Error("Konfigurasi zona pace tidak valid", "error", err)
 		os.Exit(1)
 	}
+	paceZones = zones
 
-	// 2. Muat token yang tersimpan saat startup
-	loadToken()
+	// Batas zona untuk jalan/hiking (WALK_PACE_ZONE_RED, WALK_PACE_ZONE_ORANGE, WALK_PACE_ZONE_YELLOW)
+	walkPaceZones, err = loadPaceZoneConfig("WALK_PACE_ZONE", defaultWalkPaceZones)
+	if err != nil {
+		slog.Error("Konfigurasi zona pace jalan tidak valid", "error", err)
+		os.Exit(1)
+	}
 
-	// Gunakan gin.ReleaseMode jika tidak dalam development untuk mengurangi log verbosity
-	if os.Getenv("GIN_MODE") == "release" {
-		gin.SetMode(gin.ReleaseMode)
+	rateLimitRetryDelay, err = envDuration("STRAVA_RATE_LIMIT_RETRY_DELAY", 0)
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
 	}
 
-	router := gin.Default()
+	// Muat batas zona detak jantung (HR_ZONES, mis. "120,140,155,170")
+	hrZoneBounds, err = loadHRZoneConfig()
+	if err != nil {
+		slog.Error("Konfigurasi zona detak jantung tidak valid", "error", err)
+		os.Exit(1)
+	}
 
-	// --- Konfigurasi CORS (PENTING) ---
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", frontendURL)
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+	// Batas kecepatan wajar per kategori (MAX_SPEED_RUN_WALK_HIKE, MAX_SPEED_BIKE, MAX_SPEED_OTHER)
+	maxSpeeds, err = loadMaxSpeedConfig()
+	if err != nil {
+		slog.Error("Konfigurasi batas kecepatan tidak valid", "error", err)
+		os.Exit(1)
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusOK)
-			return
-		}
-		c.Next()
-	})
-	// ------------------------------------
+	cacheTTL, err = envDuration("CACHE_TTL", defaultCacheTTL)
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
+	}
 
-	// Endpoint API
-	router.GET("/api/status", handleStatus)
-	router.GET("/api/auth/strava", handleStravaLogin)
-	router.GET("/strava-callback", handleStravaCallback)
+	tokenTTLMargin, err = envSeconds("TOKEN_TTL_MARGIN_SECONDS", defaultTokenTTLMargin)
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
+	}
 
-	// Endpoint untuk data: Mengambil data aktivitas dari Strava (dengan caching lokal)
-	router.GET("/api/activities", handleGetActivities)
+	autoSyncInterval, err = envDuration("AUTO_SYNC_INTERVAL", 0)
+	if err == nil && autoSyncInterval > 0 && autoSyncInterval < minAutoSyncInterval {
+		err = fmt.Errorf("AUTO_SYNC_INTERVAL minimal %s (%q)", minAutoSyncInterval, os.Getenv("AUTO_SYNC_INTERVAL"))
+	}
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
+	}
 
-	// Endpoint untuk statistik: Menghitung dari data lokal
-	router.GET("/api/stats", handleGetDistanceStats)
-	router.GET("/api/pace-stats", handleGetPaceStats)
+	connectTimeout, err := envDuration("STRAVA_CONNECT_TIMEOUT", defaultStravaConnectTimeout)
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
+	}
+	readTimeout, err := envDuration("STRAVA_READ_TIMEOUT", defaultStravaReadTimeout)
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
+	}
+	stravaClient = newStravaClient(connectTimeout, readTimeout)
 
-	router.GET("/api/weekly-pace-stats", handleGetWeeklyPaceStats)
+	stravaPerPage, err = envPositiveInt("STRAVA_PER_PAGE", maxStravaPerPage)
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
+	}
+	if stravaPerPage > maxStravaPerPage {
+		slog.Warn("STRAVA_PER_PAGE melebihi batas Strava. Menggunakan nilai maksimal.", "value", stravaPerPage, "max", maxStravaPerPage)
+		stravaPerPage = maxStravaPerPage
+	}
 
-	fmt.Printf("Server Go berjalan di http://localhost:%s\n", port)
-	router.Run(":" + port)
-}
+	stravaMaxPages, err = envPositiveInt("STRAVA_MAX_PAGES", defaultStravaMaxPages)
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
+	}
 
-// --------------------------------------
-// TOKEN MANAGEMENT FUNCTIONS
-// --------------------------------------
+	streamSyncLimit, err = envPositiveInt("STREAM_SYNC_LIMIT", 0)
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
+	}
 
-// loadToken memuat token dari file lokal ke memori.
-func loadToken() {
-	tokenMutex.Lock()
-	defer tokenMutex.Unlock()
+	paceCategories, err = loadPaceCategories()
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
+	}
 
-	data, err := os.ReadFile(tokenFilePath)
+	runTypes, err = loadRunTypes()
 	if err != nil {
-		if !os.IsNotExist(err) {
-			fmt.Printf("Peringatan: Gagal membaca file token: %v\n", err)
-		} else {
-			fmt.Println("Peringatan: File token tidak ditemukan. Pengguna perlu login Strava.")
-		}
-		return
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
 	}
 
-	if err := json.Unmarshal(data, &currentTokens); err != nil {
-		fmt.Printf("Peringatan: Gagal mengurai file token: %v\n", err)
-		return
+	weekStart, err = loadWeekStart()
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
 	}
 
-	fmt.Printf("Token berhasil dimuat. Token kedaluwarsa pada: %s\n", time.Unix(currentTokens.ExpiresAt, 0).Format(time.RFC822))
-}
+	appLocation = loadAppLocation()
 
-// saveToken menyimpan token dari memori ke file lokal.
-func saveToken(t TokenData) error {
-	tokenMutex.Lock()
-	defer tokenMutex.Unlock()
+	tokenFileMode, err = loadTokenFileMode()
+	if err != nil {
+		slog.Error("Konfigurasi tidak valid", "error", err)
+		os.Exit(1)
+	}
 
-	// Perbarui token global di memori
-	currentTokens = t
+	// Kunci enkripsi file token (opsional, untuk kompatibilitas dengan file lama)
+	if secret := os.Getenv("TOKEN_ENCRYPTION_KEY"); secret != "" {
+		tokenEncryptionKey = deriveTokenKey(secret)
+	} else {
+		slog.Warn("TOKEN_ENCRYPTION_KEY tidak diisi. Token akan disimpan sebagai teks biasa.")
+	}
 
-	// Buat folder data jika belum ada
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return fmt.Errorf("gagal membuat direktori data: %w", err)
+	// Direktori data (DATA_DIR), mis. volume yang di-mount di Docker
+	if dir := os.Getenv("DATA_DIR"); dir != "" {
+		setDataDir(dir)
 	}
+	slog.Info("Direktori data", "path", dataDir)
 
-	data, err := json.MarshalIndent(t, "", " ")
+	// Override klasifikasi tipe aktivitas (opsional, data/classification.json)
+	overrides, err := loadClassificationConfig()
 	if err != nil {
-		return fmt.Errorf("gagal marshal token: %w", err)
+		slog.Error("Konfigurasi klasifikasi tidak valid", "path", classificationFilePath, "error", err)
+		os.Exit(1)
 	}
-
-	if err := os.WriteFile(tokenFilePath, data, 0644); err != nil {
-		return fmt.Errorf("gagal menulis file token: %w", err)
+	setClassificationOverrides(overrides)
+	if len(overrides) > 0 {
+		slog.Info("Override klasifikasi dimuat", "path", classificationFilePath, "type_count", len(overrides))
 	}
-	fmt.Printf("Token baru berhasil disimpan. Kedaluwarsa pada: %s\n", time.Unix(t.ExpiresAt, 0).Format(time.RFC822))
-	return nil
-}
 
-// refreshAccessToken menukar refresh token lama dengan access token baru.
-func refreshAccessToken() error {
+	// 2. Muat token yang tersimpan saat startup
+	loadToken()
+
+	// Gunakan gin.ReleaseMode jika tidak dalam development untuk mengurangi log verbosity
+	if os.Getenv("GIN_MODE") == "release" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	server := newServer(cfg)
+
+	srv := &http.Server{
+		Addr:    ":" + cfg.Port,
+		Handler: server.routes(),
+	}
+
+	// Tangani SIGINT/SIGTERM agar sinkronisasi yang sedang berjalan sempat selesai
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		fmt.Printf("Server Go berjalan di http://localhost:%s\n", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Server gagal berjalan", "error", err)
+			os.Exit(1)
+		}
+	}()
+
+	// Strava memvalidasi callback secara sinkron saat pendaftaran, jadi daftarkan setelah server berjalan
+	if cfg.WebhookCallbackURL != "" {
+		// ID yang tersimpan dipakai sampai pendaftaran selesai (atau jika pendaftaran gagal)
+		if id, err := loadWebhookSubscriptionID(); err == nil {
+			server.webhookSubscriptionID.Store(id)
+		} else if !os.IsNotExist(err) {
+			slog.Warn("Gagal membaca ID subscription webhook", "path", webhookFilePath, "error", err)
+		}
+		go func() {
+			if err := server.registerWebhookSubscription(); err != nil {
+				slog.Error("Gagal mendaftarkan webhook Strava", "callback_url", cfg.WebhookCallbackURL, "error", err)
+			}
+		}()
+	}
+
+	// Sinkronisasi otomatis berhenti saat ctx dibatalkan oleh sinyal shutdown
+	autoSyncDone := make(chan struct{})
+	if autoSyncInterval > 0 {
+		go func() {
+			defer close(autoSyncDone)
+			server.runAutoSync(ctx, autoSyncInterval)
+		}()
+	} else {
+		close(autoSyncDone)
+	}
+
+	<-ctx.Done()
+	stop()
+	slog.Info("Sinyal shutdown diterima. Menunggu request yang sedang berjalan selesai...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownGracePeriod)
+	defer cancel()
+	select {
+	case <-autoSyncDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("Sinkronisasi otomatis tidak berhenti sebelum batas waktu shutdown")
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Shutdown tidak selesai dengan bersih", "error", err)
+		return
+	}
+	slog.Info("Server berhenti.")
+}
+
+// routes membangun router gin beserta middleware dan seluruh endpoint.
+func (s *Server) routes() *gin.Engine {
+	// Log akses ditulis oleh requestIDMiddleware (slog JSON), menggantikan logger teks bawaan gin
+	router := gin.New()
+	router.Use(gin.Recovery(), requestIDMiddleware())
+	// Metode yang salah pada path yang ada dijawab 405, bukan 404
+	router.HandleMethodNotAllowed = true
+
+	// Probe liveness/readiness didaftarkan sebelum middleware CORS agar tetap ringan
+	router.GET("/healthz", s.handleHealthz)
+	router.GET("/readyz", s.handleReadyz)
+
+	// --- Konfigurasi CORS (PENTING) ---
+	router.Use(func(c *gin.Context) {
+		c.Writer.Header().Set("Access-Control-Allow-Origin", s.cfg.FrontendURL)
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Request-ID, If-None-Match")
+		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, X-Request-ID, X-Cache-Incomplete, X-Cache-Covered-From, X-Cache-Covered-Until, ETag")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusOK)
+			return
+		}
+		c.Next()
+	})
+	// ------------------------------------
+
+	// Kompresi gzip untuk respons besar (mis. /api/activities)
+	router.Use(gzipMiddleware())
+
+	// Endpoint API
+	router.GET("/api/status", s.handleStatus)
+	router.GET("/api/auth/strava", s.handleStravaLogin)
+	router.GET(callbackPath, s.handleStravaCallback)
+	router.POST("/api/auth/logout", s.handleLogout)
+	router.POST("/api/auth/refresh", s.handleRefreshToken)
+
+	// Endpoint untuk data: Mengambil data aktivitas dari Strava (dengan caching lokal)
+	router.GET("/api/activities", s.handleGetActivities)
+	router.GET("/api/activities/recent", s.handleGetRecentActivities)
+	router.GET("/api/activities/search", s.handleSearchActivities)
+	router.GET("/api/activities/typed", s.handleGetTypedActivities)
+	router.GET("/api/activities/:id", s.handleGetActivityByID)
+	router.GET("/api/activities/:id/splits", s.handleGetActivitySplits)
+	router.GET("/api/activities/:id/export.gpx", s.handleExportActivityGPX)
+
+	// Endpoint untuk statistik: Menghitung dari data lokal (?repair=true memulihkan cache yang rusak)
+	stats := router.Group("", s.cacheRepairMiddleware())
+	stats.GET("/api/stats", s.handleGetDistanceStats)
+	stats.GET("/api/pace-stats", s.handleGetPaceStats)
+	stats.GET("/api/pace-zones", s.handleGetPaceZones)
+	stats.GET("/api/pace-distribution", s.handleGetPaceDistribution)
+	stats.GET("/api/pace-trend", s.handleGetPaceTrend)
+	stats.GET("/api/yearly-stats", s.handleGetYearlyStats)
+	stats.GET("/api/available-periods", s.handleGetAvailablePeriods)
+	stats.GET("/api/calendar", s.handleGetCalendar)
+	stats.GET("/api/stats/summary", s.handleGetSummary)
+	stats.GET("/api/stats/category/:category", s.handleGetCategoryStats)
+	stats.GET("/api/rolling-stats", s.handleGetRollingStats)
+	stats.GET("/api/social-stats", s.handleGetSocialStats)
+	stats.GET("/api/streaks", s.handleGetStreaks)
+	stats.GET("/api/ytd-comparison", s.handleGetYTDComparison)
+	stats.GET("/api/weekday-distribution", s.handleGetWeekdayDistribution)
+	stats.GET("/api/avg-weekly-mileage", s.handleGetAvgWeeklyMileage)
+
+	stats.GET("/api/personal-records", s.handleGetPersonalRecords)
+	stats.GET("/api/data/validate", s.handleValidateData)
+	stats.GET("/api/hr-stats", s.handleGetHRStats)
+	stats.GET("/api/efficiency-stats", s.handleGetEfficiencyStats)
+	stats.GET("/api/climb-stats", s.handleGetClimbStats)
+	stats.GET("/api/gear-stats", s.handleGetGearStats)
+
+	stats.GET("/api/weekly-pace-stats", s.handleGetWeeklyPaceStats)
+	stats.GET("/api/weekly-distance-stats", s.handleGetWeeklyDistanceStats)
+
+	// Goal jarak bulanan
+	router.POST("/api/goals", s.handleSetGoal)
+	stats.GET("/api/goals/progress", s.handleGetGoalProgress)
+	stats.GET("/api/goals/rings", s.handleGetGoalRings)
+
+	// Admin: bangun ulang state turunan setelah konfigurasi berubah (tanpa memanggil Strava)
+	router.POST("/api/admin/recompute", s.handleAdminRecompute)
+
+	// Webhook Strava: validasi subscription (GET) dan event aktivitas (POST)
+	router.GET("/api/webhook", s.handleWebhookValidation)
+	router.POST("/api/webhook", s.handleWebhookEvent)
+
+	// Respons JSON untuk path dan metode yang tidak dikenal (middleware global seperti CORS tetap berlaku)
+	router.NoRoute(func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": msg(c, "route_not_found"), "path": c.Request.URL.Path})
+	})
+	router.NoMethod(func(c *gin.Context) {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": msg(c, "method_not_allowed"), "method": c.Request.Method, "path": c.Request.URL.Path})
+	})
+
+	return router
+}
+
+// gzipMinSize adalah ukuran body minimal (byte) sebelum respons dikompresi.
+const gzipMinSize = 1024
+
+// gzipMiddleware mengompresi respons dengan gzip jika klien mengirim Accept-Encoding: gzip
+// dan body mencapai gzipMinSize. Respons yang sudah memiliki Content-Encoding tidak dikompresi ulang.
+func gzipMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		if c.Request.Method == http.MethodHead || !acceptsGzip(c.Request) {
+			c.Next()
+			return
+		}
+
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer writer.finish()
+
+		c.Next()
+	}
+}
+
+// acceptsGzip memeriksa apakah header Accept-Encoding mengizinkan gzip (q=0 berarti ditolak).
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		q, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// gzipResponseWriter menampung body hingga gzipMinSize sebelum memutuskan apakah respons dikompresi.
+// Body kecil dikirim apa adanya; body besar atau respons yang di-Flush dikirim melalui gzip.Writer.
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+	passthrough bool // true jika keputusan sudah diambil untuk tidak mengompresi
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	switch {
+	case w.gz != nil:
+		return w.gz.Write(data)
+	case w.passthrough:
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf.Write(data)
+	if w.buf.Len() >= gzipMinSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush memulai kompresi lebih awal agar respons streaming tetap terkirim bertahap.
+func (w *gzipResponseWriter) Flush() {
+	if w.gz == nil && !w.passthrough {
+		w.start(true)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// start mengirim isi buffer, dikompresi jika compress bernilai true dan header masih dapat diubah.
+func (w *gzipResponseWriter) start(compress bool) error {
+	header := w.Header()
+	if compress && !w.ResponseWriter.Written() && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+		_, err := w.gz.Write(w.buf.Bytes())
+		w.buf.Reset()
+		return err
+	}
+
+	w.passthrough = true
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// finish mengirim sisa buffer (tanpa kompresi jika di bawah gzipMinSize) dan menutup gzip.Writer.
+func (w *gzipResponseWriter) finish() {
+	if w.gz == nil && !w.passthrough {
+		if err := w.start(false); err != nil {
+			slog.Warn("Gagal menulis respons", "error", err)
+		}
+	}
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			slog.Warn("Gagal menutup writer gzip", "error", err)
+		}
+	}
+}
+
+// loadConfig membaca konfigurasi server dari environment variables.
+func loadConfig() (Config, error) {
+	cfg := Config{
+		ClientID:     os.Getenv("STRAVA_CLIENT_ID"),
+		ClientSecret: os.Getenv("STRAVA_CLIENT_SECRET"),
+		RedirectURI:  os.Getenv("STRAVA_REDIRECT_URI"),
+		FrontendURL:  "http://localhost:5173",
+		Scope:        os.Getenv("STRAVA_SCOPE"),
+		Port:         os.Getenv("BACKEND_PORT"),
+
+		WebhookCallbackURL: os.Getenv("WEBHOOK_CALLBACK_URL"),
+		WebhookVerifyToken: os.Getenv("WEBHOOK_VERIFY_TOKEN"),
+	}
+
+	if cfg.Port == "" {
+		cfg.Port = "8080" // Default port
+	}
+	if cfg.Scope == "" {
+		cfg.Scope = "read,activity:read_all"
+	}
+	if cfg.RedirectURI != "" {
+		if u, err := url.Parse(cfg.RedirectURI); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return cfg, fmt.Errorf("STRAVA_REDIRECT_URI harus URL http(s) absolut (%q)", cfg.RedirectURI)
+		}
+	}
+
+	if raw := os.Getenv("STRAVA_PKCE"); raw != "" {
+		usePKCE, err := strconv.ParseBool(raw)
+		if err != nil {
+			return cfg, fmt.Errorf("STRAVA_PKCE bukan boolean yang valid (%q): %w", raw, err)
+		}
+		cfg.UsePKCE = usePKCE
+	}
+
+	if cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return cfg, fmt.Errorf("STRAVA_CLIENT_ID atau STRAVA_CLIENT_SECRET tidak ditemukan")
+	}
+
+	if cfg.WebhookCallbackURL != "" && cfg.WebhookVerifyToken == "" {
+		// Token verifikasi hanya perlu cocok antara pendaftaran dan validasi oleh proses ini
+		token, err := randomToken()
+		if err != nil {
+			return cfg, fmt.Errorf("gagal membuat token verifikasi webhook: %w", err)
+		}
+		cfg.WebhookVerifyToken = token
+	}
+
+	return cfg, nil
+}
+
+// --------------------------------------
+// TOKEN MANAGEMENT FUNCTIONS
+// --------------------------------------
+
+// loadToken memuat token dari file lokal ke memori.
+func loadToken() {
+	tokenMutex.Lock()
+	defer tokenMutex.Unlock()
+	defer tokensLoaded.Store(true)
+
+	data, err := dataFS.ReadFile(tokenFilePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Gagal membaca file token", "path", tokenFilePath, "error", err)
+		} else {
+			slog.Warn("File token tidak ditemukan. Pengguna perlu login Strava.", "path", tokenFilePath)
+		}
+		return
+	}
+
+	tokens, err := decodeTokenFile(data)
+	if err != nil {
+		slog.Warn("Gagal mengurai file token", "path", tokenFilePath, "error", err)
+		return
+	}
+	currentTokens = tokens
+
+	slog.Info("Token berhasil dimuat",
+		"athlete_id", currentTokens.AthleteID,
+		"expires_at", time.Unix(currentTokens.ExpiresAt, 0).Format(time.RFC3339))
+}
+
+// saveToken menyimpan token dari memori ke file lokal.
+func saveToken(t TokenData) error {
+	tokenMutex.Lock()
+	defer tokenMutex.Unlock()
+
+	// Perbarui token global di memori
+	currentTokens = t
+
+	// Buat folder data jika belum ada
+	if err := dataFS.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("gagal membuat direktori data: %w", err)
+	}
+
+	var data []byte
+	var err error
+	if tokenEncryptionKey != nil {
+		data, err = encryptTokenData(t, tokenEncryptionKey)
+	} else {
+		data, err = json.MarshalIndent(t, "", " ")
+	}
+	if err != nil {
+		return fmt.Errorf("gagal marshal token: %w", err)
+	}
+
+	if err := dataFS.WriteFile(tokenFilePath, data, tokenFileMode); err != nil {
+		return fmt.Errorf("gagal menulis file token: %w", err)
+	}
+	slog.Info("Token baru berhasil disimpan",
+		"athlete_id", t.AthleteID,
+		"expires_at", time.Unix(t.ExpiresAt, 0).Format(time.RFC3339))
+	return nil
+}
+
+// clearToken menghapus token dari memori dan file lokal sehingga pengguna harus login ulang.
+// refreshMutex diambil lebih dulu agar refresh yang sedang berjalan tidak menulis ulang token lama.
+func clearToken() error {
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+	tokenMutex.Lock()
+	defer tokenMutex.Unlock()
+
+	currentTokens = TokenData{}
+
+	if err := dataFS.Remove(tokenFilePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("gagal menghapus file token: %w", err)
+	}
+	slog.Info("Token dihapus. Pengguna perlu login Strava.", "path", tokenFilePath)
+	return nil
+}
+
+// deriveTokenKey menurunkan kunci AES-256 dari secret TOKEN_ENCRYPTION_KEY.
+func deriveTokenKey(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+// encryptTokenData mengenkripsi TokenData dengan AES-GCM dan mengembalikan isi file token.
+func encryptTokenData(t TokenData, key []byte) ([]byte, error) {
+	plaintext, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newTokenGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("gagal membuat nonce: %w", err)
+	}
+
+	return json.MarshalIndent(encryptedTokenFile{
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	}, "", " ")
+}
+
+// decryptTokenData membalik encryptTokenData.
+func decryptTokenData(data []byte, key []byte) (TokenData, error) {
+	var t TokenData
+
+	var envelope encryptedTokenFile
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return t, err
+	}
+
+	gcm, err := newTokenGCM(key)
+	if err != nil {
+		return t, err
+	}
+	if len(envelope.Nonce) != gcm.NonceSize() {
+		return t, fmt.Errorf("panjang nonce tidak valid")
+	}
+
+	plaintext, err := gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	if err != nil {
+		return t, fmt.Errorf("gagal mendekripsi token (kunci salah atau file rusak): %w", err)
+	}
+
+	err = json.Unmarshal(plaintext, &t)
+	return t, err
+}
+
+func newTokenGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat cipher AES: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// decodeTokenFile mengurai isi file token, baik terenkripsi maupun teks biasa (format lama).
+func decodeTokenFile(data []byte) (TokenData, error) {
+	var envelope encryptedTokenFile
+	if err := json.Unmarshal(data, &envelope); err == nil && len(envelope.Ciphertext) > 0 {
+		if tokenEncryptionKey == nil {
+			return TokenData{}, fmt.Errorf("file token terenkripsi, tetapi TOKEN_ENCRYPTION_KEY tidak diisi")
+		}
+		return decryptTokenData(data, tokenEncryptionKey)
+	}
+
+	var t TokenData
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, err
+	}
+	if tokenEncryptionKey != nil {
+		slog.Warn("File token masih berupa teks biasa. Token akan dienkripsi saat disimpan berikutnya.")
+	}
+	return t, nil
+}
+
+// refreshAccessToken menukar refresh token lama dengan access token baru.
+// tokenMutex hanya dipegang saat membaca/menulis currentTokens, tidak selama request HTTP.
+// Pemanggil yang berjalan konkuren harus memegang refreshMutex (lihat ensureValidToken).
+func (s *Server) refreshAccessToken(ctx context.Context) error {
+	tokenMutex.Lock()
+	tokens := currentTokens
+	tokenMutex.Unlock()
+
+	if tokens.RefreshToken == "" {
+		return errNoRefreshToken
+	}
+
+	slog.InfoContext(ctx, "Token lama kedaluwarsa. Mencoba refresh token...", "athlete_id", tokens.AthleteID)
+
+	data := url.Values{}
+	data.Set("client_id", s.cfg.ClientID)
+	data.Set("client_secret", s.cfg.ClientSecret)
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", tokens.RefreshToken)
+
+	// Batasi total waktu refresh (termasuk semua percobaan ulang) agar tidak menggantung.
+	// Refresh tidak ikut dibatalkan jika klien pemicu terputus, karena request lain mungkin menunggu hasilnya.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), tokenRefreshTimeout)
+	defer cancel()
+
+	var newTokens StravaTokenResponse
+	backoff := tokenRefreshInitialBackoff
+	for attempt := 1; ; attempt++ {
+		var retryable bool
+		var err error
+		newTokens, retryable, err = requestTokenRefresh(ctx, data)
+		if err == nil {
+			break
+		}
+		// Error 4xx berarti refresh token memang tidak valid; tidak perlu dicoba ulang
+		if !retryable || attempt >= tokenRefreshAttempts {
+			return err
+		}
+
+		slog.WarnContext(ctx, "Refresh token gagal sementara. Mencoba ulang...",
+			"attempt", attempt,
+			"backoff", backoff.String(),
+			"error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("refresh token dibatalkan: %w", ctx.Err())
+		}
+		backoff *= 2
+	}
+
+	tokens.AccessToken = newTokens.AccessToken
+	tokens.ExpiresAt = newTokens.ExpiresAt
+	if newTokens.RefreshToken != "" {
+		// Strava terkadang mengeluarkan refresh token baru, terkadang tidak.
+		tokens.RefreshToken = newTokens.RefreshToken
+	}
+
+	// Simpan token baru (saveToken juga memperbarui currentTokens di memori)
+	if err := saveToken(tokens); err != nil {
+		return fmt.Errorf("gagal menyimpan token yang di-refresh: %w", err)
+	}
+
+	slog.InfoContext(ctx, "Refresh token berhasil. Access token baru telah disimpan.", "athlete_id", tokens.AthleteID)
+	return nil
+}
+
+// errNoRefreshToken dikembalikan refreshAccessToken jika belum ada refresh token yang tersimpan.
+var errNoRefreshToken = errors.New("tidak ada refresh token yang tersimpan. Pengguna harus login ulang")
+
+// requestTokenRefresh mengirim satu request refresh token ke Strava.
+// retryable bernilai true untuk error jaringan dan respons 5xx.
+func requestTokenRefresh(ctx context.Context, data url.Values) (tokens StravaTokenResponse, retryable bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://www.strava.com/oauth/token", strings.NewReader(data.Encode()))
+	if err != nil {
+		return tokens, false, fmt.Errorf("gagal membuat request refresh token: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := stravaClient.Do(req)
+	if err != nil {
+		// Jangan coba ulang jika context sudah habis waktu/dibatalkan
+		return tokens, ctx.Err() == nil, fmt.Errorf("gagal request refresh token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return tokens, resp.StatusCode >= 500, fmt.Errorf("gagal refresh token. Status: %s, Body: %s", resp.Status, bodyBytes)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return tokens, false, fmt.Errorf("gagal mengurai respons refresh token: %w", err)
+	}
+
+	return tokens, false, nil
+}
+
+// tokenSnapshot membaca access token saat ini dan menentukan apakah perlu di-refresh per now.
+func tokenSnapshot(now time.Time) (accessToken string, needsRefresh bool, err error) {
 	tokenMutex.Lock()
 	defer tokenMutex.Unlock()
 
-	if currentTokens.RefreshToken == "" {
-		return fmt.Errorf("tidak ada refresh token yang tersimpan. Pengguna harus login ulang")
+	if currentTokens.AccessToken == "" {
+		return "", false, fmt.Errorf("access token tidak ada. Silakan login melalui /api/auth/strava")
+	}
+
+	// Cek apakah token akan kedaluwarsa dalam waktu dekat
+	return currentTokens.AccessToken, tokenExpiresSoon(currentTokens.ExpiresAt, now), nil
+}
+
+// tokenExpiresSoon melaporkan apakah token dengan expiresAt (Unix) sudah berada dalam tokenTTLMargin
+// per now. Batasnya inklusif: tepat 60 detik sebelum kedaluwarsa, token sudah dianggap perlu di-refresh.
+func tokenExpiresSoon(expiresAt int64, now time.Time) bool {
+	return !now.Before(time.Unix(expiresAt, 0).Add(-tokenTTLMargin))
+}
+
+// ensureValidToken memeriksa kedaluwarsa token dan melakukan refresh jika diperlukan.
+// Jika beberapa request datang bersamaan dengan token kedaluwarsa, hanya satu yang
+// melakukan refresh; sisanya menunggu lalu memakai token yang sudah diperbarui.
+// ctx membawa request ID untuk log refresh token.
+func (s *Server) ensureValidToken(ctx context.Context) (string, error) {
+	accessToken, needsRefresh, err := tokenSnapshot(s.clock.Now())
+	if err != nil || !needsRefresh {
+		return accessToken, err
+	}
+
+	refreshMutex.Lock()
+	defer refreshMutex.Unlock()
+
+	// Periksa ulang: goroutine lain mungkin sudah me-refresh selama kita menunggu.
+	accessToken, needsRefresh, err = tokenSnapshot(s.clock.Now())
+	if err != nil || !needsRefresh {
+		return accessToken, err
+	}
+
+	if err := s.refreshAccessToken(ctx); err != nil {
+		return "", err
+	}
+
+	accessToken, _, err = tokenSnapshot(s.clock.Now())
+	return accessToken, err
+}
+
+// --------------------------------------
+// HANDLER FUNCTIONS
+// --------------------------------------
+
+// Tambahkan fungsi pembantu agar dapat memuat StravaActivity lengkap untuk summary
+func loadActivitiesInStravaFormat() []StravaActivity {
+	data, err := readActivitiesFile()
+	if err != nil {
+		slog.Error("Gagal membaca file data", "path", dataFilePath, "error", err)
+		return nil
+	}
+	var activities []StravaActivity
+	if err := json.Unmarshal(data, &activities); err != nil {
+		slog.Error("Gagal mengurai aktivitas", "path", dataFilePath, "error", err)
+		return nil
+	}
+	return activities
+}
+
+// filterLocalActivities memuat aktivitas dari cache lokal dan hanya menyisakan yang dimulai
+// dalam rentang [startDate, endDate] (inklusif, berdasarkan start_date_local).
+// startDate dan endDate adalah awal hari (00:00:00) di zona waktu yang sama.
+func filterLocalActivities(filter statsFilter, startDate, endDate time.Time) []StravaActivity {
+	var inRange []StravaActivity
+	for _, activity := range loadLocalActivities(filter) {
+		if isLocalDateInRange(activity.StartDate, activity.StartDateLocal, startDate, endDate) {
+			inRange = append(inRange, activity)
+		}
+	}
+	return inRange
+}
+
+// isLocalDateInRange memeriksa apakah tanggal lokal aktivitas (localStartTime) berada dalam rentang
+// tanggal [startDate, endDate] (inklusif). Dipakai /api/activities dan endpoint statistik agar satu
+// rentang tanggal mencakup aktivitas yang sama di semua endpoint.
+func isLocalDateInRange(activityStartDate, activityStartDateLocal string, startDate, endDate time.Time) bool {
+	t, ok := localStartTime(activityStartDate, activityStartDateLocal)
+	if !ok {
+		return false
+	}
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, startDate.Location())
+	return isWithinDateRange(day, startDate, endDate)
+}
+
+// isWithinDateRange memeriksa apakah t berada dalam rentang tanggal [startDate, endDate] (inklusif).
+// startDate dan endDate adalah awal hari (00:00:00); untuk mencakup seluruh hari terakhir,
+// t harus >= startDate DAN < awal hari setelah endDate.
+func isWithinDateRange(t, startDate, endDate time.Time) bool {
+	nextDayStart := endDate.AddDate(0, 0, 1)
+	isAfterOrEqualStart := t.Equal(startDate) || t.After(startDate)
+	return isAfterOrEqualStart && t.Before(nextDayStart)
+}
+
+func (s *Server) handleStatus(c *gin.Context) {
+	now := s.clock.Now()
+
+	// Cek status file data
+	path, info, err := statActivitiesFile()
+	response := StatusResponse{
+		Status:         "Backend is running 🟢",
+		DataFile:       path,
+		CacheUpdatedAt: "N/A",
+		TokenExpires:   "N/A",
+	}
+	if err == nil {
+		cacheAgeSeconds := int64(now.Sub(info.ModTime()).Seconds())
+		response.FileStatus = "OK"
+		response.CacheAgeSeconds = &cacheAgeSeconds
+		response.CacheUpdatedAt = info.ModTime().Format(time.RFC822)
+		response.CacheStale = isCacheStale(info.ModTime(), now)
+	} else if os.IsNotExist(err) {
+		response.FileStatus = "Missing"
+	} else {
+		response.FileStatus = fmt.Sprintf("Error: %s", err.Error())
+	}
+
+	tokenMutex.Lock()
+	response.TokenValid = currentTokens.AccessToken != "" && !tokenExpiresSoon(currentTokens.ExpiresAt, now)
+	if currentTokens.ExpiresAt > 0 {
+		response.TokenExpires = time.Unix(currentTokens.ExpiresAt, 0).Format(time.RFC822)
+	}
+	response.HasRefreshToken = currentTokens.RefreshToken != ""
+	tokenMutex.Unlock()
+
+	c.JSON(http.StatusOK, response)
+}
+
+// handleHealthz: Liveness probe, selalu 200 selama proses berjalan
+func (s *Server) handleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReadyz: Readiness probe, 200 hanya jika token sudah dimuat dan direktori data dapat ditulis
+func (s *Server) handleReadyz(c *gin.Context) {
+	if !tokensLoaded.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "reason": "token belum dimuat"})
+		return
+	}
+	if err := checkDataDirWritable(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "reason": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
+// checkDataDirWritable memastikan direktori data ada dan dapat ditulis dengan menulis lalu menghapus
+// file uji. Nama file diacak agar pemeriksaan yang berjalan bersamaan tidak saling menimpa.
+func checkDataDirWritable() error {
+	if err := dataFS.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("gagal membuat direktori data: %w", err)
+	}
+	suffix, err := randomToken()
+	if err != nil {
+		return err
+	}
+	probePath := filepath.Join(dataDir, ".readyz-"+suffix)
+	if err := dataFS.WriteFile(probePath, nil, 0600); err != nil {
+		return fmt.Errorf("direktori data tidak dapat ditulis: %w", err)
+	}
+	return dataFS.Remove(probePath)
+}
+
+// Nilai yang didukung oleh parameter ?prompt= pada /api/auth/strava (diteruskan sebagai approval_prompt).
+// force selalu menampilkan layar persetujuan Strava sehingga grant baru (beserta refresh token) selalu
+// diterbitkan; ini bawaan agar otorisasi pertama pasti menghasilkan refresh token yang bisa disimpan.
+// auto melewati layar persetujuan bagi pengguna yang grant-nya masih berlaku, cocok untuk login ulang.
+const (
+	approvalPromptForce = "force"
+	approvalPromptAuto  = "auto"
+)
+
+// handleStravaLogin mengarahkan pengguna ke halaman otorisasi Strava.
+// State acak disimpan hingga callback untuk mencegah CSRF; jika PKCE aktif, code challenge ikut dikirim.
+func (s *Server) handleStravaLogin(c *gin.Context) {
+	prompt := c.DefaultQuery("prompt", approvalPromptForce)
+	if prompt != approvalPromptForce && prompt != approvalPromptAuto {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_approval_prompt")})
+		return
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		slog.Error("Gagal membuat state OAuth", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "oauth_state_failed")})
+		return
+	}
+
+	// Parameter di-encode lewat url.Values agar redirect URI dan koma pada scope ter-escape dengan benar
+	params := url.Values{}
+	params.Set("client_id", s.cfg.ClientID)
+	params.Set("response_type", "code")
+	params.Set("redirect_uri", s.redirectURIFor(c))
+	params.Set("scope", s.cfg.Scope)
+	params.Set("state", state)
+	params.Set("approval_prompt", prompt)
+
+	pending := pendingAuth{createdAt: s.clock.Now()}
+	if s.cfg.UsePKCE {
+		verifier, err := randomPKCEVerifier()
+		if err != nil {
+			slog.Error("Gagal membuat PKCE code verifier", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "oauth_state_failed")})
+			return
+		}
+		pending.codeVerifier = verifier
+		params.Set("code_challenge", pkceChallenge(verifier))
+		params.Set("code_challenge_method", "S256")
+	}
+	s.oauthStates.add(state, pending)
+
+	authURL := url.URL{
+		Scheme:   "https",
+		Host:     "www.strava.com",
+		Path:     "/oauth/authorize",
+		RawQuery: params.Encode(),
+	}
+	c.Redirect(http.StatusFound, authURL.String())
+}
+
+// callbackPath adalah path callback OAuth yang didaftarkan di routes().
+const callbackPath = "/strava-callback"
+
+// redirectURIFor mengembalikan redirect URI untuk otorisasi: STRAVA_REDIRECT_URI jika diisi,
+// atau URL callback pada host yang sama dengan request login. Di belakang reverse proxy,
+// X-Forwarded-Proto dan X-Forwarded-Host dipakai agar URI sesuai dengan alamat publik.
+// Strava tetap hanya menerima redirect ke domain callback yang didaftarkan di pengaturan aplikasi.
+func (s *Server) redirectURIFor(c *gin.Context) string {
+	if s.cfg.RedirectURI != "" {
+		return s.cfg.RedirectURI
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := firstHeaderValue(c.GetHeader("X-Forwarded-Proto")); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := c.Request.Host
+	if forwardedHost := firstHeaderValue(c.GetHeader("X-Forwarded-Host")); forwardedHost != "" {
+		host = forwardedHost
+	}
+
+	return (&url.URL{Scheme: scheme, Host: host, Path: callbackPath}).String()
+}
+
+// firstHeaderValue mengambil nilai pertama dari header yang dipisah koma (mis. dari beberapa proxy).
+func firstHeaderValue(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.ToLower(strings.TrimSpace(first))
+}
+
+// oauthStateTTL adalah batas waktu antara redirect login dan callback Strava.
+const oauthStateTTL = 10 * time.Minute
+
+// pendingAuth adalah data otorisasi yang menunggu callback Strava.
+type pendingAuth struct {
+	codeVerifier string // Kosong jika PKCE tidak aktif
+	createdAt    time.Time
+}
+
+// oauthStateStore menyimpan state OAuth yang dibuat handleStravaLogin hingga dipakai callback.
+type oauthStateStore struct {
+	mu      sync.Mutex
+	pending map[string]pendingAuth
+}
+
+func newOAuthStateStore() *oauthStateStore {
+	return &oauthStateStore{pending: make(map[string]pendingAuth)}
+}
+
+// add menyimpan state yang menunggu callback. State kedaluwarsa dibuang sekalian agar map tidak terus tumbuh.
+func (st *oauthStateStore) add(state string, auth pendingAuth) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for key, existing := range st.pending {
+		if auth.createdAt.Sub(existing.createdAt) > oauthStateTTL {
+			delete(st.pending, key)
+		}
+	}
+	st.pending[state] = auth
+}
+
+// take mengambil dan menghapus state. Mengembalikan false jika state kosong, tidak dikenal, atau kedaluwarsa.
+func (st *oauthStateStore) take(state string, now time.Time) (pendingAuth, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	auth, ok := st.pending[state]
+	if state == "" || !ok {
+		return pendingAuth{}, false
+	}
+	delete(st.pending, state)
+	if now.Sub(auth.createdAt) > oauthStateTTL {
+		return pendingAuth{}, false
+	}
+	return auth, true
+}
+
+// randomPKCEVerifier membuat code verifier PKCE (43 karakter base64url, RFC 7636).
+func randomPKCEVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// pkceChallenge menghitung code challenge S256 dari code verifier.
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// handleStravaCallback menangani respons dari Strava dan menukar kode otorisasi dengan token.
+func (s *Server) handleStravaCallback(c *gin.Context) {
+	// State harus cocok dengan yang dibuat handleStravaLogin; setiap state hanya dapat dipakai sekali
+	pending, ok := s.oauthStates.take(c.Query("state"), s.clock.Now())
+	if !ok {
+		slog.Warn("Callback OAuth dengan state tidak dikenal atau kedaluwarsa ditolak")
+		c.Redirect(http.StatusTemporaryRedirect, s.cfg.FrontendURL+"/?auth_status=invalid_state")
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		if c.Query("error") != "" {
+			// Pengguna menolak otorisasi
+			c.Redirect(http.StatusTemporaryRedirect, s.cfg.FrontendURL+"/?auth_status=denied")
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "auth_code_missing")})
+		return
+	}
+
+	data := url.Values{}
+	data.Set("client_id", s.cfg.ClientID)
+	data.Set("client_secret", s.cfg.ClientSecret)
+	data.Set("code", code)
+	data.Set("grant_type", "authorization_code")
+	if pending.codeVerifier != "" {
+		data.Set("code_verifier", pending.codeVerifier)
+	}
+
+	// Lakukan penukaran token
+	resp, err := stravaClient.PostForm("https://www.strava.com/oauth/token", data)
+	if err != nil {
+		slog.Error("Gagal request token ke Strava", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "token_request_failed")})
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		slog.Error("Penukaran token Strava gagal", "status", resp.Status, "body", string(bodyBytes))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "token_exchange_failed"), "status": resp.Status, "response": string(bodyBytes)})
+		return
+	}
+
+	var tokenResponse StravaTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
+		slog.Error("Gagal mengurai respons token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "token_decode_failed")})
+		return
+	}
+
+	// --- FIX: Simpan SEMUA data token (termasuk refresh token) ke file lokal ---
+	tokenData := TokenData{
+		AccessToken:  tokenResponse.AccessToken,
+		RefreshToken: tokenResponse.RefreshToken,
+		ExpiresAt:    tokenResponse.ExpiresAt,
+		AthleteID:    tokenResponse.Athlete.ID,
+	}
+	if err := saveToken(tokenData); err != nil {
+		slog.Error("Gagal menyimpan token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "token_save_failed")})
+		return
+	}
+
+	// Alihkan ke frontend. Token kini dikelola di backend.
+	slog.Info("Token berhasil didapatkan dan disimpan. Mengarahkan ke frontend.", "athlete_id", tokenData.AthleteID)
+	c.Redirect(http.StatusTemporaryRedirect, fmt.Sprintf("%s/?auth_status=success", s.cfg.FrontendURL))
+}
+
+// handleLogout: Menghapus token yang tersimpan (memori dan file) sehingga endpoint terproteksi merespons 401
+func (s *Server) handleLogout(c *gin.Context) {
+	if err := clearToken(); err != nil {
+		slog.Error("Gagal menghapus token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "logout_failed"), "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "logged_out"})
+}
+
+// handleRefreshToken: Memaksa refresh token tanpa menunggu kedaluwarsa (untuk debug).
+// Hanya waktu kedaluwarsa baru yang dikirim; access token tidak pernah dikembalikan ke klien.
+func (s *Server) handleRefreshToken(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	refreshMutex.Lock()
+	err := s.refreshAccessToken(ctx)
+	refreshMutex.Unlock()
+	if err != nil {
+		if errors.Is(err, errNoRefreshToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "no_refresh_token")})
+			return
+		}
+		// Error dari Strava (termasuk body respons) diteruskan di details
+		slog.ErrorContext(ctx, "Refresh token manual gagal", "error", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": msg(c, "token_refresh_failed"), "details": err.Error()})
+		return
+	}
+
+	tokenMutex.Lock()
+	expiresAt := currentTokens.ExpiresAt
+	tokenMutex.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "refreshed",
+		"expires_at": time.Unix(expiresAt, 0).UTC().Format(time.RFC3339),
+	})
+}
+
+// handleGetActivities: Logika Caching dan Refresh Token
+func (s *Server) handleGetActivities(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// Pastikan token valid atau refresh token
+	accessToken, err := s.ensureValidToken(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Gagal memeriksa/refresh token", "error", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": msg(c, "token_invalid_relogin"), "details": err.Error()})
+		return
+	}
+
+	shouldRefresh := c.Query("refresh") == "true"
+
+	// mode=incremental hanya mengambil aktivitas yang lebih baru dari cache
+	mode := c.Query("mode")
+	if mode != "" && mode != "incremental" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_mode")})
+		return
+	}
+	incremental := mode == "incremental"
+
+	// ?after=&before= membatasi sinkronisasi paksa ke rentang tanggal tertentu
+	window, ok := parseSyncWindow(c, shouldRefresh)
+	if !ok {
+		return
+	}
+
+	// Filter hanya diterapkan pada respons; cache di disk tetap berisi semua aktivitas
+	filter, ok := parseActivityFilter(c)
+	if !ok {
+		return
+	}
+
+	// 1. Cek file lokal dan kondisi refresh
+	_, info, err := statActivitiesFile()
+	fileExist := err == nil
+
+	if fileExist && !shouldRefresh && !incremental {
+		// Cache kedaluwarsa: perbarui secara inkremental, tetapi tetap kirim cache lama jika Strava tidak dapat dijangkau
+		if now := s.clock.Now(); isCacheStale(info.ModTime(), now) {
+			slog.InfoContext(ctx, "Cache melebihi CACHE_TTL. Memperbarui otomatis...",
+				"cache_age", now.Sub(info.ModTime()).Round(time.Second).String(),
+				"cache_ttl", cacheTTL.String())
+			if err := fetchAndMergeNewActivities(ctx, accessToken); err != nil {
+				slog.WarnContext(ctx, "Gagal memperbarui cache, menggunakan data lama", "error", err)
+				c.Header("X-Cache-Stale", "true")
+			}
+		}
+
+		if filter.hasDateRange {
+			s.markCacheCoverage(c, filter.startDate, filter.endDate)
+		}
+
+		// Logika membaca file lokal yang sama
+		slog.DebugContext(ctx, "Membaca data dari file lokal", "path", dataFilePath)
+		if err := streamCachedActivities(c, filter); err != nil {
+			slog.WarnContext(ctx, "File JSON lokal rusak. Mencoba mengambil data baru...", "path", dataFilePath, "error", err)
+			if isCorruptCacheError(err) {
+				if err := backupCorruptCache(ctx); err != nil {
+					slog.WarnContext(ctx, "Gagal mencadangkan file cache rusak", "error", err)
+				}
+			}
+		} else {
+			return
+		}
+	}
+
+	// 2. Ambil data baru jika file tidak ada/rusak ATAU refresh diminta
+	// Gunakan accessToken yang sudah dipastikan valid/baru dari ensureValidToken
+	var syncErr error
+	switch {
+	case shouldRefresh && window.active():
+		slog.InfoContext(ctx, "Memaksa refresh untuk rentang tanggal. Mengambil aktivitas dari Strava...",
+			"after", window.after, "before", window.before)
+		syncErr = fetchAndMergeActivityWindow(ctx, accessToken, window)
+	case shouldRefresh:
+		slog.InfoContext(ctx, "Memaksa refresh. Mengambil semua data baru dari Strava...")
+		syncErr = fetchAndSaveAllActivities(ctx, accessToken)
+	case incremental:
+		slog.InfoContext(ctx, "Sinkronisasi inkremental. Mengambil aktivitas baru dari Strava...")
+		syncErr = fetchAndMergeNewActivities(ctx, accessToken)
+	default:
+		slog.InfoContext(ctx, "File lokal tidak ditemukan atau rusak. Mengambil data dari Strava...")
+		syncErr = fetchAndSaveAllActivities(ctx, accessToken)
+	}
+
+	if syncErr != nil {
+		slog.ErrorContext(ctx, "Sinkronisasi aktivitas gagal", "error", syncErr)
+		var rateLimitErr *RateLimitError
+		if errors.As(syncErr, &rateLimitErr) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":    msg(c, "rate_limited"),
+				"reset_at": rateLimitErr.ResetAt.Format(time.RFC3339),
+				"usage":    rateLimitErr.Usage,
+				"limit":    rateLimitErr.Limit,
+				"daily":    rateLimitErr.Daily,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "sync_failed"), "details": syncErr.Error()})
+		return
+	}
+
+	if err := syncVelocityStreams(ctx, accessToken); err != nil {
+		slog.WarnContext(ctx, "Sinkronisasi stream kecepatan gagal", "error", err)
+	}
+
+	// 3. Kirim data yang baru disimpan ke frontend
+	if err := streamCachedActivities(c, filter); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "read_after_sync_failed"), "details": err.Error()})
+	}
+}
+
+// syncWindow membatasi sinkronisasi ke aktivitas yang dimulai dalam [after, before).
+// Nilai zero berarti tidak ada batas di sisi tersebut.
+type syncWindow struct {
+	after  time.Time
+	before time.Time
+}
+
+func (w syncWindow) active() bool {
+	return !w.after.IsZero() || !w.before.IsZero()
+}
+
+// contains melaporkan apakah startDate berada di dalam rentang sinkronisasi.
+func (w syncWindow) contains(startDate time.Time) bool {
+	if !w.after.IsZero() && startDate.Before(w.after) {
+		return false
+	}
+	if !w.before.IsZero() && !startDate.Before(w.before) {
+		return false
+	}
+	return true
+}
+
+// parseSyncWindow membaca ?after=YYYY-MM-DD&before=YYYY-MM-DD (UTC). Kedua tanggal inklusif,
+// jadi before digeser ke awal hari berikutnya. Rentang hanya berlaku bersama ?refresh=true.
+// Mengembalikan false (dan sudah mengirim respons 400) jika format tidak valid.
+func parseSyncWindow(c *gin.Context, refresh bool) (syncWindow, bool) {
+	var window syncWindow
+	afterQuery := c.Query("after")
+	beforeQuery := c.Query("before")
+	if afterQuery == "" && beforeQuery == "" {
+		return window, true
+	}
+	if !refresh {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "sync_window_requires_refresh")})
+		return window, false
+	}
+
+	var err error
+	if afterQuery != "" {
+		window.after, err = time.ParseInLocation("2006-01-02", afterQuery, time.UTC)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_sync_after")})
+			return window, false
+		}
+	}
+	if beforeQuery != "" {
+		window.before, err = time.ParseInLocation("2006-01-02", beforeQuery, time.UTC)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_sync_before")})
+			return window, false
+		}
+		window.before = window.before.AddDate(0, 0, 1)
+	}
+	if !window.after.IsZero() && !window.before.IsZero() && !window.after.Before(window.before) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_sync_window")})
+		return window, false
+	}
+	return window, true
+}
+
+// activitiesETag menurunkan ETag dari mtime dan ukuran file cache serta query string request,
+// karena filter dan paginasi menghasilkan body yang berbeda dari file yang sama. ETag bersifat weak
+// karena body yang sama dapat dikirim dengan atau tanpa kompresi gzip.
+func activitiesETag(info os.FileInfo, rawQuery string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d-%d-%s", info.ModTime().UnixNano(), info.Size(), rawQuery)))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// etagMatches memeriksa header If-None-Match (daftar ETag dipisah koma atau "*") terhadap etag.
+// Perbandingan bersifat weak: prefiks W/ diabaikan di kedua sisi.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheRepairMiddleware menangani ?repair=true pada endpoint statistik: jika file cache aktivitas
+// tidak dapat diurai, file dicadangkan ke "<file>.bak", semua aktivitas diambil ulang dari Strava,
+// lalu cache dibaca ulang sekali sebelum handler dijalankan. Tanpa parameter ini, cache yang rusak
+// tetap menghasilkan error dari handler seperti biasa.
+func (s *Server) cacheRepairMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("repair") != "true" {
+			return
+		}
+		_, err := getCachedActivities()
+		if err == nil || !isCorruptCacheError(err) {
+			return
+		}
+
+		ctx := c.Request.Context()
+		slog.WarnContext(ctx, "File cache aktivitas rusak. Memulihkan dari Strava...", "path", dataFilePath, "error", err)
+		if err := backupCorruptCache(ctx); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": msg(c, "cache_repair_failed"), "details": err.Error()})
+			return
+		}
+
+		accessToken, err := s.ensureValidToken(ctx)
+		if err == nil {
+			err = fetchAndSaveAllActivities(ctx, accessToken)
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Pemulihan cache gagal", "error", err)
+			c.AbortWithStatusJSON(http.StatusBadGateway, gin.H{"error": msg(c, "cache_repair_failed"), "details": err.Error()})
+			return
+		}
+
+		// Coba sekali lagi; jika masih gagal, data dari Strava sendiri tidak dapat dipakai
+		if _, err := getCachedActivities(); err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": msg(c, "local_file_parse_failed"), "details": err.Error()})
+			return
+		}
+		slog.InfoContext(ctx, "Cache aktivitas berhasil dipulihkan", "path", dataFilePath)
+	}
+}
+
+// isCorruptCacheError melaporkan apakah err berasal dari isi file cache yang tidak dapat diurai
+// (bukan dari file yang tidak ada atau tidak dapat dibaca).
+func isCorruptCacheError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.As(err, &syntaxErr) || errors.As(err, &typeErr) ||
+		errors.Is(err, gzip.ErrHeader) || errors.Is(err, gzip.ErrChecksum) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backupCorruptCache memindahkan file cache yang rusak ke "<file>.bak" (menimpa cadangan lama)
+// agar sinkronisasi berikutnya menulis file baru tanpa kehilangan isi lama untuk diperiksa.
+func backupCorruptCache(ctx context.Context) error {
+	activitiesFileMutex.Lock()
+	defer activitiesFileMutex.Unlock()
+
+	path, _, err := statActivitiesFile()
+	if err != nil {
+		return fmt.Errorf("gagal mencadangkan file cache rusak: %w", err)
+	}
+	backupPath := path + ".bak"
+	if err := dataFS.Rename(path, backupPath); err != nil {
+		return fmt.Errorf("gagal mencadangkan file cache rusak: %w", err)
+	}
+	invalidateActivityCache()
+	slog.InfoContext(ctx, "File cache rusak dicadangkan", "path", backupPath)
+	return nil
+}
+
+// streamCachedActivities mengirim cache aktivitas ke klien tanpa me-marshal ulang seluruh daftar.
+// Tanpa filter, file didekompresi sambil disalin dengan io.Copy; dengan filter, aktivitas diurai, difilter,
+// lalu di-encode satu per satu. Error hanya dikembalikan sebelum respons mulai ditulis.
+func streamCachedActivities(c *gin.Context, filter activityFilter) error {
+	// Conditional GET: cache yang tidak berubah sejak respons sebelumnya dijawab 304 tanpa body
+	if _, info, err := statActivitiesFile(); err == nil {
+		etag := activitiesETag(info, c.Request.URL.RawQuery)
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "no-cache")
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Status(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	if filter.active() {
+		activities, err := getCachedRawActivities()
+		if err != nil {
+			return err
+		}
+		respondActivities(c, filter, activities)
+		return nil
+	}
+
+	// File diurai lewat cache memori (murah jika belum berubah) agar file rusak terdeteksi sebelum header dikirim
+	_, total, err := loadActivityCache()
+	if err != nil {
+		return err
+	}
+	file, err := openActivitiesFile()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, file); err != nil {
+		slog.Warn("Gagal mengirim file aktivitas", "path", dataFilePath, "error", err)
+	}
+	return nil
+}
+
+// handleGetActivityByID: Mengembalikan satu aktivitas dari cache berdasarkan ID.
+// Dengan ?fetch=true, aktivitas yang tidak ada di cache diambil dari Strava lalu digabungkan ke cache.
+func (s *Server) handleGetActivityByID(c *gin.Context) {
+	activityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || activityID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_activity_id")})
+		return
+	}
+
+	// Cache yang belum ada diperlakukan sebagai daftar kosong
+	activities, err := getCachedRawActivities()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "local_file_read_failed"), "details": err.Error()})
+		return
+	}
+	if activity := findActivityByID(activities, activityID); activity != nil {
+		c.JSON(http.StatusOK, activity)
+		return
+	}
+
+	if c.Query("fetch") != "true" {
+		c.JSON(http.StatusNotFound, gin.H{"error": msg(c, "activity_not_found")})
+		return
+	}
+
+	ctx := c.Request.Context()
+	accessToken, err := s.ensureValidToken(ctx)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": msg(c, "token_invalid_relogin"), "details": err.Error()})
+		return
+	}
+
+	activity, err := fetchSingleActivity(ctx, accessToken, activityID)
+	if err != nil {
+		if errors.Is(err, errActivityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": msg(c, "activity_not_found")})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": msg(c, "activity_fetch_failed"), "details": err.Error()})
+		return
+	}
+
+	if err := cacheActivity(activity); err != nil {
+		// Aktivitas tetap dikirim; cache akan diperbarui pada sinkronisasi berikutnya
+		slog.Warn("Gagal menyimpan aktivitas ke cache", "activity_id", activityID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, activity)
+}
+
+// handleGetRecentActivities: Mengembalikan N aktivitas terbaru dari cache (?limit=10, maks. 50),
+// diurutkan berdasarkan start_date menurun. Cache yang belum ada menghasilkan array kosong.
+func (s *Server) handleGetRecentActivities(c *gin.Context) {
+	stats, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultRecentLimit
+	if limitQuery := c.Query("limit"); limitQuery != "" {
+		parsed, err := strconv.Atoi(limitQuery)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_limit")})
+			return
+		}
+		limit = min(parsed, maxRecentLimit)
+	}
+
+	activities, err := getCachedRawActivities()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusOK, []map[string]interface{}{})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "local_file_read_failed"), "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mostRecentActivities(activityFilter{stats: stats}.apply(activities), limit))
+}
+
+// mostRecentActivities mengembalikan hingga limit aktivitas dengan start_date terbaru (menurun).
+// Slice masukan tidak diubah.
+func mostRecentActivities(activities []map[string]interface{}, limit int) []map[string]interface{} {
+	sorted := make([]map[string]interface{}, len(activities))
+	copy(sorted, activities)
+
+	// start_date selalu RFC3339 UTC (sufiks Z), sehingga urutan string sama dengan urutan waktu
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, _ := sorted[i]["start_date"].(string)
+		b, _ := sorted[j]["start_date"].(string)
+		return a > b
+	})
+
+	return sorted[:min(limit, len(sorted))]
+}
+
+// handleSearchActivities: Mencari aktivitas di cache yang namanya memuat ?q= (tidak peka huruf besar/kecil).
+// Filter dan paginasi /api/activities (?type=, ?startDate=&endDate=, ?page=, ?enrich=) juga berlaku.
+// Tidak ada yang cocok, ?q= kosong, atau cache belum ada menghasilkan array kosong.
+func (s *Server) handleSearchActivities(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+
+	filter, ok := parseActivityFilter(c)
+	if !ok {
+		return
+	}
+
+	activities, err := getCachedRawActivities()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusOK, []map[string]interface{}{})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "local_file_read_failed"), "details": err.Error()})
+		return
+	}
+
+	respondActivities(c, filter, searchActivitiesByName(activities, query))
+}
+
+// handleGetTypedActivities: Mengembalikan aktivitas dari cache sebagai TypedActivity (tipe field tetap, tanpa
+// ambiguitas float/int pada map mentah) beserta kecepatan, pace, dan zona pace. Filter ?type=, ?startDate=&endDate=,
+// ?include_private=, ?include_commute=, dan paginasi /api/activities berlaku. Cache belum ada menghasilkan array kosong.
+func (s *Server) handleGetTypedActivities(c *gin.Context) {
+	filter, ok := parseActivityFilter(c)
+	if !ok {
+		return
+	}
+
+	activities, err := getCachedActivities()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusOK, []TypedActivity{})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "local_file_read_failed"), "details": err.Error()})
+		return
+	}
+
+	filtered := filter.applyTyped(activities)
+	c.Header("X-Total-Count", strconv.Itoa(len(filtered)))
+
+	if filter.paginated {
+		start := min((filter.page-1)*filter.perPage, len(filtered))
+		filtered = filtered[start:min(start+filter.perPage, len(filtered))]
+	}
+
+	typed := make([]TypedActivity, 0, len(filtered))
+	for _, activity := range filtered {
+		typed = append(typed, newTypedActivity(activity))
+	}
+	c.JSON(http.StatusOK, typed)
+}
+
+// newTypedActivity menambahkan kecepatan rata-rata, pace (menit/km), dan zona pace ke aktivitas.
+// Zona hanya diisi untuk aktivitas RunWalkHike atau tipe lari, sama dengan /api/weekly-pace-stats.
+func newTypedActivity(activity StravaActivity) TypedActivity {
+	typed := TypedActivity{StravaActivity: activity}
+	speed, ok := averageSpeed(activity.Distance, activity.MovingTime)
+	if !ok {
+		return typed
+	}
+
+	pace := 1000.0 / speed / 60.0
+	typed.AvgSpeedMPS = &speed
+	typed.PaceMinPerKm = &pace
+	if classifyActivity(activity.Type) == "RunWalkHike" {
+		typed.PaceZone = paceZoneForActivity(activity.Type, speed).Key()
+	}
+	return typed
+}
+
+// applyTyped sama dengan apply, tetapi untuk aktivitas bertipe. Slice masukan tidak diubah.
+func (f activityFilter) applyTyped(activities []StravaActivity) []StravaActivity {
+	activities = f.stats.apply(activities)
+	if len(f.types) == 0 && !f.hasDateRange {
+		return activities
+	}
+
+	filtered := make([]StravaActivity, 0, len(activities))
+	for _, activity := range activities {
+		if len(f.types) > 0 && !f.types[strings.ToLower(activity.Type)] {
+			continue
+		}
+		if f.hasDateRange && !isLocalDateInRange(activity.StartDate, activity.StartDateLocal, f.startDate, f.endDate) {
+			continue
+		}
+		filtered = append(filtered, activity)
+	}
+	return filtered
+}
+
+// searchActivitiesByName mengembalikan aktivitas yang field name-nya memuat query
+// (substring, tidak peka huruf besar/kecil). Query kosong tidak cocok dengan aktivitas apa pun.
+// Slice masukan tidak diubah.
+func searchActivitiesByName(activities []map[string]interface{}, query string) []map[string]interface{} {
+	query = strings.ToLower(query)
+	matches := make([]map[string]interface{}, 0)
+	if query == "" {
+		return matches
+	}
+	for _, activity := range activities {
+		name, _ := activity["name"].(string)
+		if strings.Contains(strings.ToLower(name), query) {
+			matches = append(matches, activity)
+		}
+	}
+	return matches
+}
+
+// handleGetActivitySplits: Mengembalikan split per kilometer satu aktivitas.
+// Split diambil dari detail aktivitas Strava (splits_metric) sekali, lalu dibaca dari data/splits/<id>.json.
+func (s *Server) handleGetActivitySplits(c *gin.Context) {
+	activityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || activityID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_activity_id")})
+		return
+	}
+
+	splits, err := loadCachedSplits(activityID)
+	if err == nil {
+		c.JSON(http.StatusOK, splits)
+		return
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "local_file_read_failed"), "details": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	accessToken, err := s.ensureValidToken(ctx)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": msg(c, "token_invalid_relogin"), "details": err.Error()})
+		return
+	}
+
+	activity, err := fetchSingleActivity(ctx, accessToken, activityID)
+	if err != nil {
+		if errors.Is(err, errActivityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": msg(c, "activity_not_found")})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": msg(c, "activity_fetch_failed"), "details": err.Error()})
+		return
+	}
+
+	splits = extractMetricSplits(activity)
+	if err := saveCachedSplits(activityID, splits); err != nil {
+		// Split tetap dikirim; akan diambil ulang dari Strava pada request berikutnya
+		slog.Warn("Gagal menyimpan cache split", "activity_id", activityID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, splits)
+}
+
+// handleExportActivityGPX: Mengunduh satu aktivitas sebagai file GPX 1.1 yang dibangun dari
+// stream latlng, time, dan altitude Strava. Aktivitas tanpa data GPS dijawab 422.
+func (s *Server) handleExportActivityGPX(c *gin.Context) {
+	activityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || activityID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_activity_id")})
+		return
+	}
+
+	ctx := c.Request.Context()
+	accessToken, err := s.ensureValidToken(ctx)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": msg(c, "token_invalid_relogin"), "details": err.Error()})
+		return
+	}
+
+	// Nama dan waktu mulai diambil dari cache jika ada, selain itu dari Strava
+	var activity map[string]interface{}
+	if cached, err := getCachedRawActivities(); err == nil {
+		activity = findActivityByID(cached, activityID)
+	}
+	if activity == nil {
+		activity, err = fetchSingleActivity(ctx, accessToken, activityID)
+		if err != nil {
+			if errors.Is(err, errActivityNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": msg(c, "activity_not_found")})
+				return
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": msg(c, "activity_fetch_failed"), "details": err.Error()})
+			return
+		}
+	}
+
+	streams, err := fetchActivityStreams(ctx, accessToken, activityID, gpxStreamKeys)
+	if err != nil {
+		if errors.Is(err, errActivityNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": msg(c, "activity_not_found")})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": msg(c, "activity_fetch_failed"), "details": err.Error()})
+		return
+	}
+	// Stream kecepatan disimpan agar zona pace aktivitas ini dapat dihitung per sampel (lihat paceStatsForActivity)
+	if err := saveCachedVelocityStream(activityID, streams); err != nil {
+		slog.WarnContext(c.Request.Context(), "Gagal menyimpan cache stream kecepatan", "activity_id", activityID, "error", err)
+	}
+	if len(streams.LatLng.Data) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg(c, "activity_no_gps")})
+		return
+	}
+
+	data, err := buildActivityGPX(activity, streams)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "gpx_build_failed"), "details": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="activity-%d.gpx"`, activityID))
+	c.Data(http.StatusOK, "application/gpx+xml", data)
+}
+
+// respondActivities menerapkan filter dan paginasi lalu mengirim aktivitas sebagai JSON.
+// Header X-Total-Count berisi jumlah aktivitas setelah filter (sebelum paginasi).
+func respondActivities(c *gin.Context, filter activityFilter, activities []map[string]interface{}) {
+	filtered := filter.apply(activities)
+	c.Header("X-Total-Count", strconv.Itoa(len(filtered)))
+
+	page := filter.paginate(filtered)
+	if filter.enrich {
+		page = enrichActivities(page)
+	}
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := encodeJSONArray(c.Writer, page); err != nil {
+		slog.Warn("Gagal mengirim aktivitas", "error", err)
+	}
+}
+
+// encodeJSONArray menulis items sebagai array JSON, satu elemen per Encode, tanpa membangun
+// seluruh array di memori terlebih dahulu.
+func encodeJSONArray(w io.Writer, items []map[string]interface{}) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	for i, item := range items {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Encode(item); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// enrichActivities mengembalikan salinan aktivitas dengan tambahan avg_speed_mps dan pace_min_per_km.
+// Aktivitas tanpa jarak atau waktu bergerak (mis. WeightTraining) mendapat nilai null.
+// Map asli tidak diubah.
+func enrichActivities(activities []map[string]interface{}) []map[string]interface{} {
+	enriched := make([]map[string]interface{}, len(activities))
+	for i, activity := range activities {
+		copied := make(map[string]interface{}, len(activity)+2)
+		for key, value := range activity {
+			copied[key] = value
+		}
+
+		distance, _ := getFloat(activity["distance"])
+		movingTime, _ := getFloat(activity["moving_time"])
+		if speed, ok := averageSpeed(distance, movingTime); ok {
+			copied["avg_speed_mps"] = speed
+			copied["pace_min_per_km"] = 1000.0 / speed / 60.0
+		} else {
+			copied["avg_speed_mps"] = nil
+			copied["pace_min_per_km"] = nil
+		}
+
+		enriched[i] = copied
+	}
+	return enriched
+}
+
+// averageSpeed menghitung kecepatan rata-rata (m/s) dari jarak (meter) dan waktu bergerak (detik).
+// Mengembalikan false jika salah satu nilai tidak positif.
+func averageSpeed(distanceM, movingTimeS float64) (float64, bool) {
+	if distanceM <= 0 || movingTimeS <= 0 {
+		return 0, false
+	}
+	return distanceM / movingTimeS, true
+}
+
+// isCacheStale memeriksa apakah cache dengan waktu modifikasi modTime sudah melewati cacheTTL per now.
+func isCacheStale(modTime, now time.Time) bool {
+	return cacheTTL > 0 && now.Sub(modTime) > cacheTTL
+}
+
+// activityFilter menampung filter respons /api/activities.
+type activityFilter struct {
+	types map[string]bool // Tipe aktivitas (huruf kecil) yang diizinkan; kosong berarti semua tipe
+
+	// Rentang tanggal lokal (APP_TIMEZONE, inklusif) yang dibandingkan dengan localStartTime aktivitas;
+	// hanya aktif jika hasDateRange bernilai true
+	hasDateRange bool
+	startDate    time.Time
+	endDate      time.Time
+
+	// Paginasi hanya aktif jika ?page atau ?per_page diberikan, agar klien lama tetap menerima semua aktivitas
+	paginated bool
+	page      int
+	perPage   int
+
+	// enrich menambahkan avg_speed_mps dan pace_min_per_km ke setiap aktivitas (?enrich=true)
+	enrich bool
+
+	// Filter yang sama dengan endpoint statistik (?include_private=false)
+	stats statsFilter
+}
+
+// Batas paginasi /api/activities
+const (
+	defaultActivitiesPerPage = 50
+	maxActivitiesPerPage     = 200
+)
+
+// Batas jumlah aktivitas /api/activities/recent
+const (
+	defaultRecentLimit = 10
+	maxRecentLimit     = 50
+)
+
+// parseActivityFilter membaca query ?type=Run atau ?type=Run,Ride (tidak peka huruf besar/kecil)
+// serta ?startDate=YYYY-MM-DD&endDate=YYYY-MM-DD.
+// Mengembalikan false (dan sudah mengirim respons 400) jika parameter tidak valid.
+func parseActivityFilter(c *gin.Context) (activityFilter, bool) {
+	filter := activityFilter{types: make(map[string]bool), enrich: c.Query("enrich") == "true"}
+
+	stats, ok := parseStatsFilter(c)
+	if !ok {
+		return filter, false
+	}
+	filter.stats = stats
+	for _, activityType := range strings.Split(c.Query("type"), ",") {
+		activityType = strings.TrimSpace(activityType)
+		if activityType != "" {
+			filter.types[strings.ToLower(activityType)] = true
+		}
+	}
+
+	pageQuery := c.Query("page")
+	perPageQuery := c.Query("per_page")
+	if pageQuery != "" || perPageQuery != "" {
+		filter.paginated = true
+		filter.page = 1
+		filter.perPage = defaultActivitiesPerPage
+
+		if pageQuery != "" {
+			page, err := strconv.Atoi(pageQuery)
+			if err != nil || page < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_page")})
+				return filter, false
+			}
+			filter.page = page
+		}
+		if perPageQuery != "" {
+			perPage, err := strconv.Atoi(perPageQuery)
+			if err != nil || perPage < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_per_page")})
+				return filter, false
+			}
+			filter.perPage = min(perPage, maxActivitiesPerPage)
+		}
+	}
+
+	startQuery := c.Query("startDate")
+	endQuery := c.Query("endDate")
+	if startQuery == "" && endQuery == "" {
+		return filter, true
+	}
+	if startQuery == "" || endQuery == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "date_range_incomplete")})
+		return filter, false
+	}
+
+	var err error
+	filter.startDate, err = time.ParseInLocation("2006-01-02", startQuery, appLocation)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_start_date")})
+		return filter, false
+	}
+	filter.endDate, err = time.ParseInLocation("2006-01-02", endQuery, appLocation)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_end_date")})
+		return filter, false
+	}
+	if filter.endDate.Before(filter.startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "end_before_start")})
+		return filter, false
+	}
+	filter.hasDateRange = true
+
+	return filter, true
+}
+
+// active melaporkan apakah respons perlu diurai per aktivitas (filter, paginasi, atau enrich).
+func (f activityFilter) active() bool {
+	return len(f.types) > 0 || f.hasDateRange || f.paginated || f.enrich || !f.stats.includesAll()
+}
+
+// paginate mengembalikan halaman aktivitas yang diminta. Halaman di luar jangkauan menghasilkan array kosong.
+func (f activityFilter) paginate(activities []map[string]interface{}) []map[string]interface{} {
+	if !f.paginated {
+		return activities
+	}
+
+	start := (f.page - 1) * f.perPage
+	if start >= len(activities) {
+		return []map[string]interface{}{}
+	}
+	end := min(start+f.perPage, len(activities))
+	return activities[start:end]
+}
+
+// apply mengembalikan aktivitas yang lolos filter.
+func (f activityFilter) apply(activities []map[string]interface{}) []map[string]interface{} {
+	if len(f.types) == 0 && !f.hasDateRange && f.stats.includesAll() {
+		return activities
+	}
+
+	filtered := make([]map[string]interface{}, 0, len(activities))
+	for _, activity := range activities {
+		if private, _ := activity["private"].(bool); private && !f.stats.includePrivate {
+			continue
+		}
+		if commute, _ := activity["commute"].(bool); commute && !f.stats.includeCommute {
+			continue
+		}
+
+		if len(f.types) > 0 {
+			activityType, _ := activity["type"].(string)
+			if !f.types[strings.ToLower(activityType)] {
+				continue
+			}
+		}
+
+		if f.hasDateRange {
+			startDate, _ := activity["start_date"].(string)
+			startDateLocal, _ := activity["start_date_local"].(string)
+			if !isLocalDateInRange(startDate, startDateLocal, f.startDate, f.endDate) {
+				continue
+			}
+		}
+
+		filtered = append(filtered, activity)
+	}
+	return filtered
+}
+
+// loadLocalActivities mengembalikan aktivitas dari cache memori, atau nil (dengan log) jika gagal dibaca.
+// Aktivitas yang tidak lolos filter tidak disertakan.
+func loadLocalActivities(filter statsFilter) []StravaActivity {
+	activities, err := getCachedActivities()
+	if err != nil {
+		slog.Error("Gagal memuat aktivitas lokal", "path", dataFilePath, "error", err)
+		return nil
+	}
+	return filter.apply(activities)
+}
+
+func calculatePaceStats(activity StravaActivity) PaceStat {
+	var stats PaceStat
+
+	// Hanya proses aktivitas lari (RUN_TYPES), jalan, dan hiking
+	if classifyActivity(activity.Type) != "RunWalkHike" {
+		return stats // Mengembalikan PaceStat kosong
+	}
+
+	// Kecepatan rata-rata (meter/detik) dari jarak (meter) dan waktu bergerak (detik)
+	avgSpeedMPS, ok := averageSpeed(activity.Distance, activity.MovingTime)
+	if !ok {
+		return stats
+	}
+
+	// Zona pace ilustratif (sesuai dengan frontend).
+	paceZone := paceZoneForActivity(activity.Type, avgSpeedMPS)
+
+	// Konversi jarak total ke KM
+	distanceKM := activity.Distance / 1000.0
+
+	// Distribusikan Jarak total ke zona yang ditentukan
+	switch paceZone {
+	case PaceZoneRed:
+		stats.Red = distanceKM
+	case PaceZoneOrange:
+		stats.Orange = distanceKM
+	case PaceZoneYellow:
+		stats.Yellow = distanceKM
+	case PaceZoneGreen:
+		stats.Green = distanceKM
+	}
+
+	return stats
+}
+
+// paceStatsForActivity membagi jarak aktivitas ke zona pace per sampel dari stream kecepatan yang
+// tersimpan di data/streams (lebih akurat untuk interval), atau memakai kecepatan rata-rata
+// (calculatePaceStats) jika stream belum tersimpan atau kosong. Stream diisi saat sinkronisasi
+// (syncVelocityStreams, jika STREAM_SYNC_LIMIT diisi) dan saat ekspor GPX. File stream hanya dibaca
+// untuk ID di streamIDs (hasil listCachedStreamIDs).
+func paceStatsForActivity(activity StravaActivity, streamIDs map[int64]bool) PaceStat {
+	if classifyActivity(activity.Type) != "RunWalkHike" || !streamIDs[activity.ID] {
+		return calculatePaceStats(activity)
+	}
+
+	stream, err := loadCachedVelocityStream(activity.ID)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Gagal membaca cache stream kecepatan", "activity_id", activity.ID, "error", err)
+		}
+		return calculatePaceStats(activity)
+	}
+	if stats, ok := calculateStreamPaceStats(activity, stream); ok {
+		return stats
+	}
+	return calculatePaceStats(activity)
+}
+
+// listCachedStreamIDs mengembalikan ID aktivitas yang stream kecepatannya tersimpan di data/streams.
+// Direktori dibaca sekali per request agar aktivitas tanpa stream tidak memicu pembacaan file satu per satu.
+func listCachedStreamIDs() map[int64]bool {
+	ids := make(map[int64]bool)
+	entries, err := dataFS.ReadDir(streamsDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Gagal membaca direktori cache stream", "path", streamsDir, "error", err)
+		}
+		return ids
+	}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if id, err := strconv.ParseInt(name, 10, 64); err == nil {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// calculateStreamPaceStats memasukkan jarak setiap segmen stream (selisih distance antar sampel) ke zona
+// kecepatan sampel tersebut, lalu menskalakan hasilnya agar totalnya sama dengan jarak aktivitas di cache.
+// Mengembalikan false jika stream tidak berisi jarak (mis. panjang stream tidak cocok atau aktivitas tanpa GPS).
+func calculateStreamPaceStats(activity StravaActivity, stream velocityStream) (PaceStat, bool) {
+	var stats PaceStat
+	if len(stream.Distance) < 2 || len(stream.Distance) != len(stream.Velocity) {
+		return stats, false
+	}
+
+	total := 0.0
+	for i := 1; i < len(stream.Distance); i++ {
+		segment := stream.Distance[i] - stream.Distance[i-1]
+		if segment <= 0 {
+			continue
+		}
+		total += segment
+
+		switch paceZoneForActivity(activity.Type, stream.Velocity[i]) {
+		case PaceZoneRed:
+			stats.Red += segment
+		case PaceZoneOrange:
+			stats.Orange += segment
+		case PaceZoneYellow:
+			stats.Yellow += segment
+		case PaceZoneGreen:
+			stats.Green += segment
+		}
+	}
+	if total == 0 {
+		return stats, false
+	}
+
+	// Skala ke KM dan ke jarak aktivitas, karena jarak stream bisa sedikit berbeda dari distance ringkasan
+	scale := activity.Distance / total / 1000.0
+	stats.Red *= scale
+	stats.Orange *= scale
+	stats.Yellow *= scale
+	stats.Green *= scale
+	return stats, true
+}
+
+// PaceStat digunakan untuk mengembalikan data agregasi statistik
+// CATATAN: Struktur ini tidak lagi digunakan, tetapi dipertahankan agar kode kompilasi
+// type PaceStat struct {
+// 	PaceDistances map[string]float64 `json:"paceDistances"`
+// }
+
+// requestIDHeader membawa ID request dari/ke klien untuk menelusuri log frontend dan backend.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength membatasi X-Request-ID dari klien agar tidak membanjiri log.
+const maxRequestIDLength = 128
+
+// requestIDContextKey adalah kunci context untuk request ID.
+type requestIDContextKey struct{}
+
+// requestIDFromContext mengembalikan request ID di ctx, atau "" jika tidak ada.
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// requestIDMiddleware memberi setiap request sebuah ID (memakai X-Request-ID dari klien jika valid),
+// mengirimnya kembali di header X-Request-ID, menyimpannya di context request agar log hilir
+// (refresh token, sinkronisasi) ikut mencatatnya, lalu menulis log akses setelah request selesai.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(requestIDHeader)
+		if !isValidRequestID(id) {
+			generated, err := randomToken()
+			if err != nil {
+				slog.Warn("Gagal membuat request ID", "error", err)
+			}
+			id = generated
+		}
+
+		ctx := context.WithValue(c.Request.Context(), requestIDContextKey{}, id)
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(requestIDHeader, id)
+
+		c.Next()
+
+		slog.InfoContext(ctx, "Request selesai",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"latency_ms", time.Since(start).Milliseconds())
+	}
+}
+
+// isValidRequestID menerima ID dari klien yang tidak kosong, tidak terlalu panjang,
+// dan hanya berisi karakter ASCII yang tampak (tanpa spasi atau karakter kontrol).
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDLogHandler menambahkan atribut request_id ke setiap log yang ditulis dengan context
+// berisi request ID (slog.InfoContext, dll.).
+type requestIDLogHandler struct {
+	slog.Handler
+}
+
+func (h requestIDLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h requestIDLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestIDLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestIDLogHandler) WithGroup(name string) slog.Handler {
+	return requestIDLogHandler{h.Handler.WithGroup(name)}
+}
+
+// setupLogger memasang logger JSON (log/slog) sebagai logger default.
+// Level diambil dari LOG_LEVEL (debug, info, warn, error); bawaan info.
+func setupLogger() {
+	var level slog.Level
+	raw := os.Getenv("LOG_LEVEL")
+	levelErr := level.UnmarshalText([]byte(raw))
+	if raw == "" || levelErr != nil {
+		level = slog.LevelInfo
+	}
+
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
+	slog.SetDefault(slog.New(requestIDLogHandler{handler}))
+
+	if raw != "" && levelErr != nil {
+		slog.Warn("LOG_LEVEL tidak dikenal, menggunakan info", "log_level", raw)
+	}
+}
+
+// envDuration membaca durasi Go (mis. "30s", "15m") dari environment variable.
+// Jika variabel kosong, nilai def dikembalikan.
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return def, fmt.Errorf("%s bukan durasi yang valid (%q): %w", name, raw, err)
+	}
+	if d < 0 {
+		return def, fmt.Errorf("%s tidak boleh negatif (%q)", name, raw)
+	}
+	return d, nil
+}
+
+// newStravaClient membuat HTTP client untuk Strava. connectTimeout membatasi koneksi TCP dan
+// handshake TLS; readTimeout membatasi total satu request termasuk membaca body. Nilai 0 berarti tanpa batas.
+func newStravaClient(connectTimeout, readTimeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+
+	return &http.Client{Transport: transport, Timeout: readTimeout}
+}
+
+// envSeconds membaca environment variable berisi jumlah detik (bilangan bulat non-negatif).
+// Mengembalikan def jika variabel tidak diisi.
+func envSeconds(name string, def time.Duration) (time.Duration, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def, nil
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil {
+		return def, fmt.Errorf("%s bukan bilangan bulat yang valid (%q): %w", name, raw, err)
+	}
+	if seconds < 0 {
+		return def, fmt.Errorf("%s tidak boleh negatif (%q)", name, raw)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// envPositiveInt membaca environment variable berisi bilangan bulat positif.
+// Mengembalikan def jika variabel tidak diisi.
+func envPositiveInt(name string, def int) (int, error) {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return def, fmt.Errorf("%s bukan bilangan bulat yang valid (%q): %w", name, raw, err)
+	}
+	if n < 1 {
+		return def, fmt.Errorf("%s harus lebih dari 0 (%q)", name, raw)
+	}
+	return n, nil
+}
+
+// loadPaceZoneConfig membaca batas zona pace dari environment variables <prefix>_RED,
+// <prefix>_ORANGE, dan <prefix>_YELLOW. Variabel yang kosong memakai nilai dari defaults.
+func loadPaceZoneConfig(prefix string, defaults PaceZoneConfig) (PaceZoneConfig, error) {
+	cfg := defaults
+
+	fields := []struct {
+		envName string
+		target  *float64
+	}{
+		{prefix + "_RED", &cfg.Red},
+		{prefix + "_ORANGE", &cfg.Orange},
+		{prefix + "_YELLOW", &cfg.Yellow},
+	}
+
+	for _, f := range fields {
+		raw := os.Getenv(f.envName)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("%s bukan angka yang valid (%q): %w", f.envName, raw, err)
+		}
+		*f.target = value
+	}
+
+	if !(cfg.Red > cfg.Orange && cfg.Orange > cfg.Yellow) {
+		return cfg, fmt.Errorf("batas zona harus menurun secara ketat (red > orange > yellow), didapat red=%.2f orange=%.2f yellow=%.2f", cfg.Red, cfg.Orange, cfg.Yellow)
+	}
+
+	return cfg, nil
+}
+
+// loadMaxSpeedConfig membaca MAX_SPEED_RUN_WALK_HIKE, MAX_SPEED_BIKE, dan MAX_SPEED_OTHER (m/s).
+// Variabel yang kosong memakai defaultMaxSpeeds; nilai tidak boleh negatif (0 menonaktifkan filter).
+func loadMaxSpeedConfig() (MaxSpeedConfig, error) {
+	cfg := defaultMaxSpeeds
+
+	fields := []struct {
+		envName string
+		target  *float64
+	}{
+		{"MAX_SPEED_RUN_WALK_HIKE", &cfg.RunWalkHike},
+		{"MAX_SPEED_BIKE", &cfg.Bike},
+		{"MAX_SPEED_OTHER", &cfg.Other},
+	}
+
+	for _, f := range fields {
+		raw := os.Getenv(f.envName)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("%s bukan angka yang valid (%q): %w", f.envName, raw, err)
+		}
+		if value < 0 {
+			return cfg, fmt.Errorf("%s tidak boleh negatif (%q)", f.envName, raw)
+		}
+		*f.target = value
+	}
+
+	return cfg, nil
+}
+
+// maxSpeedFor mengembalikan batas kecepatan untuk kategori hasil classifyActivity (0 = tanpa batas).
+func (m MaxSpeedConfig) maxSpeedFor(category string) float64 {
+	switch category {
+	case "RunWalkHike":
+		return m.RunWalkHike
+	case "Bike":
+		return m.Bike
+	default:
+		return m.Other
+	}
+}
+
+// dropImplausibleActivities membuang aktivitas yang kecepatan rata-ratanya melebihi maxSpeeds
+// untuk kategorinya. Setiap aktivitas yang dibuang dicatat di log.
+func dropImplausibleActivities(activities []StravaActivity) []StravaActivity {
+	kept := make([]StravaActivity, 0, len(activities))
+	for _, activity := range activities {
+		category := classifyActivity(activity.Type)
+		limit := maxSpeeds.maxSpeedFor(category)
+		if speed, ok := averageSpeed(activity.Distance, activity.MovingTime); ok && limit > 0 && speed > limit {
+			slog.Warn("Aktivitas dengan kecepatan tidak wajar diabaikan dari statistik",
+				"activity_id", activity.ID,
+				"name", activity.Name,
+				"type", activity.Type,
+				"distance_m", activity.Distance,
+				"avg_speed_mps", speed,
+				"max_speed_mps", limit)
+			continue
+		}
+		kept = append(kept, activity)
+	}
+	return kept
+}
+
+// loadHRZoneConfig membaca batas zona detak jantung (bpm) dari HR_ZONES yang dipisahkan koma.
+// Batas harus positif dan naik secara ketat. Jika kosong, defaultHRZoneBounds dipakai.
+func loadHRZoneConfig() ([]float64, error) {
+	raw := os.Getenv("HR_ZONES")
+	if raw == "" {
+		return defaultHRZoneBounds, nil
+	}
+
+	var bounds []float64
+	for _, part := range strings.Split(raw, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("HR_ZONES berisi nilai yang bukan angka (%q): %w", part, err)
+		}
+		if value <= 0 || (len(bounds) > 0 && value <= bounds[len(bounds)-1]) {
+			return nil, fmt.Errorf("batas HR_ZONES harus positif dan naik secara ketat, didapat %q", raw)
+		}
+		bounds = append(bounds, value)
+	}
+
+	return bounds, nil
+}
+
+// getHRZoneIndex mengembalikan indeks zona (0 = zona 1) untuk detak jantung rata-rata.
+func getHRZoneIndex(heartrate float64) int {
+	zone := 0
+	for _, bound := range hrZoneBounds {
+		if heartrate >= bound {
+			zone++
+		}
+	}
+	return zone
+}
+
+// PaceZone adalah zona pace hasil klasifikasi kecepatan rata-rata.
+// Gunakan nilai ini untuk perbandingan; label emoji dari Label hanya untuk tampilan.
+type PaceZone int
+
+const (
+	PaceZoneGreen  PaceZone = iota // Easy/Recovery
+	PaceZoneYellow                 // Steady/Aerobic
+	PaceZoneOrange                 // Tempo/Threshold
+	PaceZoneRed                    // Maks/Interval
+)
+
+// paceZonesFastestFirst adalah urutan zona dari yang tercepat, dipakai oleh /api/pace-zones.
+var paceZonesFastestFirst = []PaceZone{PaceZoneRed, PaceZoneOrange, PaceZoneYellow, PaceZoneGreen}
+
+// Key mengembalikan kunci ASCII yang stabil untuk zona (sama dengan kunci JSON PaceStat).
+func (z PaceZone) Key() string {
+	switch z {
+	case PaceZoneRed:
+		return "red"
+	case PaceZoneOrange:
+		return "orange"
+	case PaceZoneYellow:
+		return "yellow"
+	default:
+		return "green"
+	}
+}
+
+// minSpeed mengembalikan batas bawah kecepatan (m/s, inklusif) zona pada zones. Green selalu 0.
+func (z PaceZone) minSpeed(zones PaceZoneConfig) float64 {
+	switch z {
+	case PaceZoneRed:
+		return zones.Red
+	case PaceZoneOrange:
+		return zones.Orange
+	case PaceZoneYellow:
+		return zones.Yellow
+	default:
+		return 0
+	}
+}
+
+// Label mengembalikan label tampilan (emoji) untuk zona.
+func (z PaceZone) Label() string {
+	switch z {
+	case PaceZoneRed:
+		return "🔴 Merah (Maks/Interval)"
+	case PaceZoneOrange:
+		return "🟠 Oranye (Tempo/Threshold)"
+	case PaceZoneYellow:
+		return "🟡 Kuning (Steady/Aerobic)"
+	default:
+		return "🟢 Hijau (Easy/Recovery)"
+	}
+}
+
+// PaceZoneForSpeed mengelompokkan kecepatan rata-rata lari (m/s) ke dalam zona
+// berdasarkan batas yang dimuat di paceZones.
+func PaceZoneForSpeed(speed float64) PaceZone {
+	return paceZoneFor(speed, paceZones)
+}
+
+// WalkPaceZoneForSpeed sama seperti PaceZoneForSpeed, tetapi memakai batas jalan/hiking di walkPaceZones.
+func WalkPaceZoneForSpeed(speed float64) PaceZone {
+	return paceZoneFor(speed, walkPaceZones)
+}
+
+// paceZoneForActivity mengelompokkan kecepatan aktivitas RunWalkHike atau lari ke zona pace.
+// Tipe lari (RUN_TYPES) memakai batas lari; jalan/hiking memakai batas yang lebih lambat.
+func paceZoneForActivity(activityType string, speed float64) PaceZone {
+	if isRunType(activityType) {
+		return PaceZoneForSpeed(speed)
+	}
+	return WalkPaceZoneForSpeed(speed)
+}
+
+// parsePaceZoneKey mengubah kunci zona ("red", "orange", "yellow", "green") menjadi PaceZone.
+func parsePaceZoneKey(key string) (PaceZone, bool) {
+	for _, zone := range paceZonesFastestFirst {
+		if strings.EqualFold(key, zone.Key()) {
+			return zone, true
+		}
+	}
+	return PaceZoneGreen, false
+}
+
+// paceZoneFor memetakan kecepatan (m/s) ke zona berdasarkan batas pada zones.
+// Setiap batas adalah batas bawah yang inklusif: kecepatan tepat sama dengan zones.Red
+// masuk Red, tepat zones.Orange masuk Orange, tepat zones.Yellow masuk Yellow.
+// Kecepatan di bawah zones.Yellow (termasuk 0 dan NaN) masuk Green.
+// Dengan batas bawaan: 4.8 m/s -> Red, 3.8 m/s -> Orange, 3.0 m/s -> Yellow, 2.99 m/s -> Green.
+func paceZoneFor(speed float64, zones PaceZoneConfig) PaceZone {
+	// Kecepatan dihitung dari distance/moving_time
+	// Semakin tinggi m/s, semakin cepat
+	switch {
+	case speed >= zones.Red:
+		return PaceZoneRed
+	case speed >= zones.Orange:
+		return PaceZoneOrange
+	case speed >= zones.Yellow:
+		return PaceZoneYellow
+	default:
+		return PaceZoneGreen
+	}
+}
+
+// PaceZoneInfo: Metadata tampilan satu zona pace untuk frontend
+type PaceZoneInfo struct {
+	Key             string  `json:"key"`
+	Label           string  `json:"label"`
+	RunMinSpeedMPS  float64 `json:"run_min_speed_mps"`  // Batas bawah inklusif untuk lari
+	WalkMinSpeedMPS float64 `json:"walk_min_speed_mps"` // Batas bawah inklusif untuk jalan/hiking
+}
+
+// handleGetPaceZones: Mengembalikan kunci, label tampilan, dan batas kecepatan setiap zona pace
+func (s *Server) handleGetPaceZones(c *gin.Context) {
+	zones := make([]PaceZoneInfo, 0, len(paceZonesFastestFirst))
+	for _, zone := range paceZonesFastestFirst {
+		zones = append(zones, PaceZoneInfo{
+			Key:             zone.Key(),
+			Label:           zone.Label(),
+			RunMinSpeedMPS:  zone.minSpeed(paceZones),
+			WalkMinSpeedMPS: zone.minSpeed(walkPaceZones),
+		})
+	}
+	c.JSON(http.StatusOK, zones)
+}
+
+// handleGetPaceDistribution: Mengembalikan histogram pace rata-rata lari dalam bucket 15 detik/km.
+// ?startDate=YYYY-MM-DD&endDate=YYYY-MM-DD membatasi rentang; tanpa keduanya semua lari dihitung.
+func (s *Server) handleGetPaceDistribution(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	startQuery := c.Query("startDate")
+	endQuery := c.Query("endDate")
+
+	var activities []StravaActivity
+	switch {
+	case startQuery == "" && endQuery == "":
+		activities = loadLocalActivities(filter)
+	case startQuery != "" && endQuery != "":
+		// Rentang tanggal di zona waktu aplikasi, sama seperti parseWeekRangeQuery
+		startDate, err := time.ParseInLocation("2006-01-02", startQuery, appLocation)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_start_date")})
+			return
+		}
+		endDate, err := time.ParseInLocation("2006-01-02", endQuery, appLocation)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_end_date")})
+			return
+		}
+		s.markCacheCoverage(c, startDate, endDate)
+		activities = filterLocalActivities(filter, startDate, endDate)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "date_range_incomplete")})
+		return
+	}
+
+	c.JSON(http.StatusOK, calculatePaceDistribution(activities))
+}
+
+// handleGetWeeklyPaceStats: Mengambil aktivitas dalam rentang tanggal dan mengagregasi jarak per zona tempo
+func (s *Server) handleGetWeeklyPaceStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	// Zona waktu aplikasi (APP_TIMEZONE) untuk "minggu ini" dan rentang tanggal
+	loc := appLocation
+
+	// 1. Ambil query params startDate dan endDate
+	startDate, endDate, ok := parseWeekRangeQuery(c, loc, s.clock.Now())
+	if !ok {
+		return
+	}
+
+	compare := c.Query("compare") == "true"
+	coverageStart := startDate
+	if compare {
+		coverageStart = startDate.AddDate(0, 0, -7)
+	}
+	coverage, incomplete := s.markCacheCoverage(c, coverageStart, endDate)
+
+	paceData, summary := buildWeeklyPaceData(filterLocalActivities(filter, startDate, endDate), startDate, endDate, loc, unit)
+
+	finalResponse := GlobalWeeklyData{
+		PaceData:           paceData,
+		Summary:            summary,
+		Units:              unit,
+		CoverageIncomplete: incomplete,
+	}
+	if incomplete && !coverage.CoveredFrom.IsZero() {
+		finalResponse.CoveredFrom = coverage.CoveredFrom.Format(time.RFC3339)
+	}
+	if incomplete && !coverage.CoveredUntil.IsZero() {
+		finalResponse.CoveredUntil = coverage.CoveredUntil.Format(time.RFC3339)
+	}
+
+	// ?compare=true: sertakan 7 hari sebelum startDate beserta selisihnya
+	if compare {
+		prevStart := startDate.AddDate(0, 0, -7)
+		prevEnd := startDate.AddDate(0, 0, -1)
+		prevPaceData, prevSummary := buildWeeklyPaceData(filterLocalActivities(filter, prevStart, prevEnd), prevStart, prevEnd, loc, unit)
+
+		finalResponse.Previous = &WeeklyPeriodData{
+			StartDate: prevStart.Format("2006-01-02"),
+			EndDate:   prevEnd.Format("2006-01-02"),
+			PaceData:  prevPaceData,
+			Summary:   prevSummary,
+		}
+
+		current, previous := paceData.totals(), prevPaceData.totals()
+		delta := &WeeklyPaceDelta{Zones: PaceStat{
+			Red:    current.Red - previous.Red,
+			Orange: current.Orange - previous.Orange,
+			Yellow: current.Yellow - previous.Yellow,
+			Green:  current.Green - previous.Green,
+		}}
+		delta.Total = delta.Zones.Red + delta.Zones.Orange + delta.Zones.Yellow + delta.Zones.Green
+		finalResponse.Delta = delta
+	}
+
+	c.JSON(http.StatusOK, finalResponse)
+}
+
+// handleGetPaceTrend: Mengembalikan pace rata-rata per bulan untuk aktivitas satu tipe (?type=, bawaan Run)
+// yang jatuh di satu zona pace (?zone=red|orange|yellow|green), untuk grafik tren.
+func (s *Server) handleGetPaceTrend(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	activityType := c.DefaultQuery("type", "Run")
+	if classifyActivity(activityType) != "RunWalkHike" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_pace_trend_type")})
+		return
+	}
+
+	zone, ok := parsePaceZoneKey(c.Query("zone"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_pace_zone")})
+		return
+	}
+
+	trend := calculatePaceTrend(loadLocalActivities(filter), activityType, zone)
+	for i := range trend {
+		trend[i].Pace = convertPace(trend[i].Pace, unit)
+	}
+	c.JSON(http.StatusOK, trend)
+}
+
+// calculatePaceTrend mengelompokkan aktivitas bertipe activityType yang pace rata-ratanya jatuh di zone
+// per bulan lokal (start_date_local), lalu menghitung pace rata-rata berbobot jarak (detik/meter).
+// Bulan tanpa aktivitas yang memenuhi syarat tidak muncul di hasil.
+func calculatePaceTrend(activities []StravaActivity, activityType string, zone PaceZone) []PaceTrendPoint {
+	type monthTotals struct {
+		time, distance float64
+		count          int
+	}
+	totals := make(map[string]monthTotals)
+
+	for _, activity := range activities {
+		if activity.Type != activityType {
+			continue
+		}
+		speed, ok := averageSpeed(activity.Distance, activity.MovingTime)
+		if !ok || paceZoneForActivity(activity.Type, speed) != zone {
+			continue
+		}
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue
+		}
+
+		month := t.Format("2006-01")
+		total := totals[month]
+		total.time += float64(activity.MovingTime)
+		total.distance += activity.Distance
+		total.count++
+		totals[month] = total
+	}
+
+	trend := make([]PaceTrendPoint, 0, len(totals))
+	for month, total := range totals {
+		trend = append(trend, PaceTrendPoint{
+			MonthYear:     month,
+			Pace:          total.time / total.distance,
+			ActivityCount: total.count,
+		})
+	}
+	sort.Slice(trend, func(i, j int) bool {
+		return trend[i].MonthYear < trend[j].MonthYear
+	})
+	return trend
+}
+
+// buildWeeklyPaceData mengagregasi jarak per zona pace per hari dalam [startDate, endDate]
+// beserta ringkasannya, dalam satuan unit. Setiap hari dalam rentang selalu ada (bernilai nol jika kosong).
+func buildWeeklyPaceData(activities []StravaActivity, startDate, endDate time.Time, loc *time.Location, unit string) (WeeklyPaceData, WeeklySummaryStats) {
+	summary := calculateWeeklySummaryStats(activities, startDate, endDate)
+
+	// Inisialisasi SEMUA HARI DALAM RENTANG KE NOL
+	weeklyData := make(WeeklyPaceData)
+	for current := startDate; current.Before(endDate.AddDate(0, 0, 1)); current = current.AddDate(0, 0, 1) {
+		weeklyData[current.Format("2006-01-02")] = PaceStat{}
+	}
+
+	streamIDs := listCachedStreamIDs()
+	for _, activity := range activities {
+		// Pastikan menggunakan StartDateLocal untuk penanggalan harian yang akurat
+		activityTime, err := parseLocalWallTime(activity.StartDateLocal, loc)
+		if err != nil {
+			continue
+		}
+
+		dateStr := activityTime.Format("2006-01-02")
+
+		paceStats := paceStatsForActivity(activity, streamIDs)
+
+		currentDayStats := weeklyData[dateStr]
+		currentDayStats.Red += paceStats.Red
+		currentDayStats.Orange += paceStats.Orange
+		currentDayStats.Yellow += paceStats.Yellow
+		currentDayStats.Green += paceStats.Green
+		weeklyData[dateStr] = currentDayStats
+	}
+
+	// Konversi satuan hanya untuk respons; jarak harian disimpan dalam KM
+	if unit == unitImperial {
+		for dateStr, stat := range weeklyData {
+			weeklyData[dateStr] = PaceStat{
+				Red:    convertDistance(stat.Red*1000, unit),
+				Orange: convertDistance(stat.Orange*1000, unit),
+				Yellow: convertDistance(stat.Yellow*1000, unit),
+				Green:  convertDistance(stat.Green*1000, unit),
+			}
+		}
+		// total_distance_km dan average_pace_sec_per_m tetap metrik sesuai nama kuncinya
+		summary.TotalDistance = convertDistance(summary.TotalDistance*1000, unit)
+		summary.AveragePace = convertPace(summary.AveragePace, unit)
+	}
+
+	return weeklyData, summary
+}
+
+// totals menjumlahkan jarak per zona untuk semua hari.
+func (d WeeklyPaceData) totals() PaceStat {
+	var total PaceStat
+	for _, stat := range d {
+		total.Red += stat.Red
+		total.Orange += stat.Orange
+		total.Yellow += stat.Yellow
+		total.Green += stat.Green
+	}
+	return total
+}
+
+// parseWeekRangeQuery membaca query params startDate dan endDate (YYYY-MM-DD).
+// Jika salah satu kosong, rentang default adalah minggu ini, dimulai pada weekStart (WEEK_START).
+// Mengembalikan false (dan sudah mengirim respons 400) jika format tanggal tidak valid.
+func parseWeekRangeQuery(c *gin.Context, loc *time.Location, now time.Time) (time.Time, time.Time, bool) {
+	startQuery := c.Query("startDate")
+	endQuery := c.Query("endDate")
+
+	var startDate, endDate time.Time
+	var err error
+
+	if startQuery != "" && endQuery != "" {
+		startDate, err = time.ParseInLocation("2006-01-02", startQuery, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_start_date")})
+			return startDate, endDate, false
+		}
+		endDate, err = time.ParseInLocation("2006-01-02", endQuery, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_end_date")})
+			return startDate, endDate, false
+		}
+	} else {
+		startDate = startOfWeek(now.In(loc), weekStart)
+		endDate = startDate.AddDate(0, 0, 6)
+	}
+
+	return startDate, endDate, true
+}
+
+// startOfWeek mengembalikan hari pertama minggu (first) pukul 00:00 (zona waktu t) dari minggu yang memuat t.
+// Dengan first = Senin (ISO), Minggu termasuk minggu yang dimulai enam hari sebelumnya;
+// dengan first = Minggu, Sabtu termasuk minggu yang dimulai enam hari sebelumnya.
+func startOfWeek(t time.Time, first time.Weekday) time.Time {
+	daysSinceStart := (int(t.Weekday()) - int(first) + 7) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceStart, 0, 0, 0, 0, t.Location())
+}
+
+// appLocation adalah zona waktu untuk pengelompokan harian/bulanan (APP_TIMEZONE atau TZ, bawaan UTC).
+var appLocation = time.UTC
+
+// loadAppLocation membaca APP_TIMEZONE (atau TZ jika kosong) sebagai nama zona IANA, mis. "Asia/Jakarta".
+// Zona yang tidak dikenal tidak menghentikan server: UTC dipakai dengan peringatan di log.
+func loadAppLocation() *time.Location {
+	name := os.Getenv("APP_TIMEZONE")
+	source := "APP_TIMEZONE"
+	if name == "" {
+		name, source = os.Getenv("TZ"), "TZ"
+	}
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		slog.Warn("Zona waktu tidak valid. Menggunakan UTC.", "env", source, "value", name, "error", err)
+		return time.UTC
+	}
+	return loc
+}
+
+// parseLocalWallTime mengurai start_date_local Strava sebagai waktu dinding di loc. Strava mengirim
+// start_date_local dengan sufiks Z walaupun nilainya adalah waktu lokal di lokasi aktivitas, sehingga
+// komponen tanggal/jamnya dipakai apa adanya (tanpa konversi zona) agar sebanding dengan rentang tanggal di loc.
+func parseLocalWallTime(startDateLocal string, loc *time.Location) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, startDateLocal)
+	if err != nil {
+		return t, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc), nil
+}
+
+// weekStart adalah hari pertama minggu untuk rentang default endpoint mingguan (WEEK_START, bawaan Senin).
+var weekStart = time.Monday
+
+// loadWeekStart membaca WEEK_START ("monday" atau "sunday", tidak peka huruf besar/kecil).
+func loadWeekStart() (time.Weekday, error) {
+	raw := os.Getenv("WEEK_START")
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "monday":
+		return time.Monday, nil
+	case "sunday":
+		return time.Sunday, nil
+	default:
+		return time.Monday, fmt.Errorf("WEEK_START harus 'monday' atau 'sunday' (%q)", raw)
+	}
+}
+
+// handleGetWeeklyDistanceStats: Mengambil aktivitas dalam rentang tanggal dan mengagregasi jarak per kategori per hari
+func (s *Server) handleGetWeeklyDistanceStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	loc := appLocation
+
+	startDate, endDate, ok := parseWeekRangeQuery(c, loc, s.clock.Now())
+	if !ok {
+		return
+	}
+
+	s.markCacheCoverage(c, startDate, endDate)
+	activities := filterLocalActivities(filter, startDate, endDate)
+
+	// Inisialisasi setiap hari dalam rentang ke nol
+	weeklyData := make(WeeklyDistanceData)
+	for current := startDate; current.Before(endDate.AddDate(0, 0, 1)); current = current.AddDate(0, 0, 1) {
+		weeklyData[current.Format("2006-01-02")] = DailySportStats{}
+	}
+
+	for _, activity := range activities {
+		activityTime, err := parseLocalWallTime(activity.StartDateLocal, loc)
+		if err != nil {
+			continue
+		}
+
+		dateStr := activityTime.Format("2006-01-02")
+		dayStats, inRange := weeklyData[dateStr]
+		if !inRange {
+			continue
+		}
+
+		distance := convertDistance(activity.Distance, unit)
+		switch classifyActivity(activity.Type) {
+		case "RunWalkHike":
+			dayStats.RunWalkHike += distance
+		case "Bike":
+			dayStats.Bike += distance
+		case "Other":
+			dayStats.Other += distance
+		}
+		weeklyData[dateStr] = dayStats
+	}
+
+	c.JSON(http.StatusOK, weeklyData)
+}
+
+// handleGetDistanceStats: Mengembalikan ringkasan statistik jarak bulanan (Sama)
+func (s *Server) handleGetDistanceStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	period, ok := parsePeriodQuery(c)
+	if !ok {
+		return
+	}
+
+	// ?expand_other=true menyertakan rincian Other per tipe Strava (other_by_type)
+	expandOther := false
+	if raw := c.Query("expand_other"); raw != "" {
+		var err error
+		expandOther, err = strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_expand_other")})
+			return
+		}
+	}
+
+	stats, err := calculateMonthlyDistanceStats(filter, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "distance_stats_failed"), "details": err.Error()})
+		return
+	}
+
+	for i := range stats {
+		stats[i].RunWalkHike = convertDistance(stats[i].RunWalkHike, unit)
+		stats[i].Bike = convertDistance(stats[i].Bike, unit)
+		stats[i].Other = convertDistance(stats[i].Other, unit)
+		if !expandOther {
+			stats[i].OtherByType = nil
+			continue
+		}
+		for activityType, distance := range stats[i].OtherByType {
+			stats[i].OtherByType[activityType] = convertDistance(distance, unit)
+		}
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// handleGetPaceStats: Mengembalikan ringkasan statistik pace bulanan (Sama)
+func (s *Server) handleGetPaceStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	stats, err := calculateMonthlyPaceStats(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "pace_stats_failed"), "details": err.Error()})
+		return
+	}
+
+	for i := range stats {
+		stats[i].RunWalkHikePace = convertPace(stats[i].RunWalkHikePace, unit)
+		stats[i].BikePace = convertPace(stats[i].BikePace, unit)
+		stats[i].OtherPace = convertPace(stats[i].OtherPace, unit)
+		stats[i].SwimPace = convertSwimPace(stats[i].SwimPace, unit)
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// handleGetCategoryStats: Mengembalikan jarak dan pace bulanan untuk satu kategori
+// (:category = run_walk_hike, bike, atau other), sehingga tampilan per kategori cukup satu request
+func (s *Server) handleGetCategoryStats(c *gin.Context) {
+	category, ok := statsCategoryParams[c.Param("category")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_stats_category")})
+		return
+	}
+
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	period, ok := parsePeriodQuery(c)
+	if !ok {
+		return
+	}
+
+	distanceStats, err := calculateMonthlyDistanceStats(filter, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "distance_stats_failed"), "details": err.Error()})
+		return
+	}
+	paceStats, err := calculateMonthlyPaceStats(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "pace_stats_failed"), "details": err.Error()})
+		return
+	}
+
+	result := buildCategoryStats(category, distanceStats, paceStats)
+	result.Category = c.Param("category")
+	for i := range result.Months {
+		result.Months[i].Distance = convertDistance(result.Months[i].Distance, unit)
+		result.Months[i].Pace = convertPace(result.Months[i].Pace, unit)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// buildCategoryStats menggabungkan jarak dan pace bulanan satu kategori. Bulan mengikuti statistik jarak
+// (sama dengan /api/stats); pace Other tidak mencakup renang, sama dengan other_pace di /api/pace-stats.
+func buildCategoryStats(category string, distanceStats []MonthlySportStats, paceStats []MonthlyPaceStats) CategoryStats {
+	paceByMonth := make(map[string]float64, len(paceStats))
+	for _, stat := range paceStats {
+		switch category {
+		case "RunWalkHike":
+			paceByMonth[stat.MonthYear] = stat.RunWalkHikePace
+		case "Bike":
+			paceByMonth[stat.MonthYear] = stat.BikePace
+		case "Other":
+			paceByMonth[stat.MonthYear] = stat.OtherPace
+		}
+	}
+
+	result := CategoryStats{Months: make([]CategoryMonthlyStats, 0, len(distanceStats))}
+	for _, stat := range distanceStats {
+		month := CategoryMonthlyStats{MonthYear: stat.MonthYear, Pace: paceByMonth[stat.MonthYear]}
+		switch category {
+		case "RunWalkHike":
+			month.Distance = stat.RunWalkHike
+		case "Bike":
+			month.Distance = stat.Bike
+		case "Other":
+			month.Distance = stat.Other
+		}
+		result.Months = append(result.Months, month)
+	}
+	return result
+}
+
+// handleGetYearlyStats: Mengembalikan ringkasan statistik jarak tahunan
+func (s *Server) handleGetYearlyStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	stats, err := calculateYearlyDistanceStats(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "yearly_stats_failed"), "details": err.Error()})
+		return
+	}
+
+	for i := range stats {
+		stats[i].RunWalkHike = convertDistance(stats[i].RunWalkHike, unit)
+		stats[i].Bike = convertDistance(stats[i].Bike, unit)
+		stats[i].Other = convertDistance(stats[i].Other, unit)
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// handleGetSummary: Mengembalikan total sepanjang masa (jarak per kategori, jumlah aktivitas, waktu bergerak)
+func (s *Server) handleGetSummary(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	summary, err := calculateLifetimeSummary(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "summary_failed"), "details": err.Error()})
+		return
+	}
+
+	summary.RunWalkHike = convertDistance(summary.RunWalkHike, unit)
+	summary.Bike = convertDistance(summary.Bike, unit)
+	summary.Other = convertDistance(summary.Other, unit)
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// handleGetSocialStats: Mengembalikan total kudos dan achievement per bulan
+func (s *Server) handleGetSocialStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	stats, err := calculateMonthlySocialStats(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "social_stats_failed"), "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// handleValidateData: Memeriksa cache mentah dan melaporkan record yang dilewati oleh statistik
+func (s *Server) handleValidateData(c *gin.Context) {
+	rawActivities, err := readRawActivities()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{"error": msg(c, "cache_not_found"), "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "local_file_read_failed"), "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, validateActivityData(rawActivities))
+}
+
+// handleGetPersonalRecords: Mengembalikan rekor pribadi lari (pace tercepat, jarak & durasi terpanjang)
+func (s *Server) handleGetPersonalRecords(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, calculatePersonalRecords(filter))
+}
+
+// handleGetHRStats: Mengembalikan total waktu lari per zona detak jantung per bulan
+func (s *Server) handleGetHRStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, calculateMonthlyHRStats(filter))
+}
+
+// handleGetEfficiencyStats: Mengembalikan rasio waktu bergerak terhadap waktu total per kategori per bulan
+func (s *Server) handleGetEfficiencyStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, calculateMonthlyEfficiency(filter))
+}
+
+// handleGetClimbStats: Mengembalikan total elevasi dan laju tanjakan per bulan untuk RunWalkHike dan Bike
+func (s *Server) handleGetClimbStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, calculateMonthlyClimbStats(filter))
+}
+
+// handleGetGearStats: Mengembalikan total jarak dan jumlah aktivitas per gear.
+// Nama gear diambil dari cache data/gear.json; gear yang belum dikenal diambil dari Strava jika token tersedia.
+func (s *Server) handleGetGearStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	stats := calculateGearStats(filter)
+	s.resolveGearNames(c.Request.Context(), stats)
+
+	for i := range stats {
+		stats[i].TotalDistance = convertDistance(stats[i].TotalDistance, unit)
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// RecomputeResult: Ringkasan hasil POST /api/admin/recompute
+type RecomputeResult struct {
+	ClassificationOverrides int            `json:"classification_overrides"` // Jumlah tipe di classification.json
+	ActivityCount           int            `json:"activity_count"`           // Aktivitas di cache memori setelah dimuat ulang
+	DroppedCount            int            `json:"dropped_count"`            // Record yang dibuang dropImplausibleActivities
+	CategoryCounts          map[string]int `json:"category_counts"`          // Jumlah aktivitas per kategori dengan klasifikasi baru
+	MonthCount              int            `json:"month_count"`              // Bulan di /api/stats
+	YearCount               int            `json:"year_count"`               // Tahun di /api/yearly-stats
+}
+
+// handleAdminRecompute: Memuat ulang classification.json dan cache aktivitas dari disk, lalu menjalankan
+// ulang agregasi utama agar perubahan konfigurasi langsung berlaku. Tidak memanggil Strava.
+// classification.json yang tidak valid ditolak dan override lama tetap dipakai.
+func (s *Server) handleAdminRecompute(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	overrides, err := loadClassificationConfig()
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": msg(c, "classification_invalid"), "details": err.Error()})
+		return
+	}
+	setClassificationOverrides(overrides)
+
+	result := RecomputeResult{
+		ClassificationOverrides: len(overrides),
+		CategoryCounts:          map[string]int{"RunWalkHike": 0, "Bike": 0, "Other": 0},
+	}
+
+	// Cache memori dibuang lalu langsung dimuat ulang (warm) agar request berikutnya tidak menunggu
+	invalidateActivityCache()
+	activities, rawCount, err := loadActivityCache()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			slog.InfoContext(ctx, "Recompute selesai tanpa cache aktivitas", "classification_overrides", result.ClassificationOverrides)
+			c.JSON(http.StatusOK, result)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "local_file_parse_failed"), "details": err.Error()})
+		return
+	}
+	result.ActivityCount = len(activities)
+	result.DroppedCount = rawCount - len(activities)
+	for _, activity := range activities {
+		result.CategoryCounts[classifyActivity(activity.Type)]++
+	}
+
+	monthly, err := calculateMonthlyDistanceStats(defaultStatsFilter, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "distance_stats_failed"), "details": err.Error()})
+		return
+	}
+	yearly, err := calculateYearlyDistanceStats(defaultStatsFilter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "distance_stats_failed"), "details": err.Error()})
+		return
+	}
+	result.MonthCount = len(monthly)
+	result.YearCount = len(yearly)
+
+	slog.InfoContext(ctx, "Recompute selesai",
+		"classification_overrides", result.ClassificationOverrides,
+		"activity_count", result.ActivityCount,
+		"dropped_count", result.DroppedCount)
+	c.JSON(http.StatusOK, result)
+}
+
+// handleGetAvailablePeriods: Mengembalikan bulan dan tahun yang memiliki aktivitas (array kosong jika cache belum ada)
+func (s *Server) handleGetAvailablePeriods(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, calculateAvailablePeriods(filter))
+}
+
+// handleGetCalendar: Mengembalikan total jarak (?metric=distance, bawaan) atau jumlah aktivitas
+// (?metric=count) per hari aktif dalam satu tahun (?year=YYYY, bawaan tahun ini), untuk heatmap kalender.
+func (s *Server) handleGetCalendar(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	year := s.clock.Now().In(appLocation).Year()
+	if raw := c.Query("year"); raw != "" {
+		t, err := time.Parse("2006", raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_year")})
+			return
+		}
+		year = t.Year()
+	}
+
+	metric := c.DefaultQuery("metric", calendarMetricDistance)
+	if metric != calendarMetricDistance && metric != calendarMetricCount {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_calendar_metric")})
+		return
+	}
+
+	calendar := calculateCalendar(loadLocalActivities(filter), year, metric)
+	if metric == calendarMetricDistance {
+		for day, distance := range calendar {
+			calendar[day] = convertDistance(distance, unit)
+		}
+	}
+	c.JSON(http.StatusOK, calendar)
+}
+
+// handleGetYTDComparison: Mengembalikan jarak kumulatif harian tahun ini dibandingkan tahun lalu
+// hingga tanggal yang sama, untuk grafik progres year-to-date
+func (s *Server) handleGetYTDComparison(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	comparison := calculateYTDComparison(loadLocalActivities(filter), s.clock.Now().In(appLocation))
+	for _, series := range [][]YTDPoint{comparison.Current, comparison.Previous} {
+		for i := range series {
+			series[i].Distance = convertDistance(series[i].Distance, unit)
+		}
+	}
+	c.JSON(http.StatusOK, comparison)
+}
+
+// handleGetWeekdayDistribution: Mengembalikan jumlah aktivitas dan total jarak per hari dalam seminggu
+// (Senin-Minggu) dari seluruh cache
+func (s *Server) handleGetWeekdayDistribution(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	distribution := calculateWeekdayDistribution(loadLocalActivities(filter))
+	for i := range distribution {
+		distribution[i].TotalDistance = convertDistance(distribution[i].TotalDistance, unit)
+	}
+	c.JSON(http.StatusOK, distribution)
+}
+
+// handleGetStreaks: Mengembalikan streak hari aktif saat ini dan terpanjang
+func (s *Server) handleGetStreaks(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, calculateStreaks(filter, s.clock.Now().In(appLocation)))
+}
+
+// handleGetAvgWeeklyMileage: Mengembalikan rata-rata jarak lari mingguan selama ?weeks=12 minggu penuh terakhir
+func (s *Server) handleGetAvgWeeklyMileage(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	weeks := defaultMileageWeeks
+	if weeksQuery := c.Query("weeks"); weeksQuery != "" {
+		parsed, err := strconv.Atoi(weeksQuery)
+		if err != nil || parsed < 1 || parsed > maxMileageWeeks {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_weeks")})
+			return
+		}
+		weeks = parsed
+	}
+
+	mileage := calculateAvgWeeklyMileage(filter, s.clock.Now().In(appLocation), weeks)
+	mileage.AverageDistance = convertDistance(mileage.AverageDistance, unit)
+	for i := range mileage.WeeklyTotals {
+		mileage.WeeklyTotals[i].Distance = convertDistance(mileage.WeeklyTotals[i].Distance, unit)
+	}
+	mileage.Units = unit
+
+	c.JSON(http.StatusOK, mileage)
+}
+
+// handleGetRollingStats: Mengembalikan total jarak 7, 30, dan 90 hari terakhir per kategori
+func (s *Server) handleGetRollingStats(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	unit, ok := parseUnitsQuery(c)
+	if !ok {
+		return
+	}
+
+	stats := calculateRollingStats(filter, s.clock.Now().In(appLocation))
+	for i := range stats.Windows {
+		stats.Windows[i].RunWalkHike = convertDistance(stats.Windows[i].RunWalkHike, unit)
+		stats.Windows[i].Bike = convertDistance(stats.Windows[i].Bike, unit)
+		stats.Windows[i].Other = convertDistance(stats.Windows[i].Other, unit)
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// --------------------------------------
+// LOGIC FUNCTIONS
+// --------------------------------------
+
+// fetchAndSaveAllActivities mengambil semua aktivitas dari Strava dan menyimpannya ke file JSON.
+// ID ganda antar halaman dibuang sebelum ditulis. Menggunakan access token yang sudah dipastikan valid.
+func fetchAndSaveAllActivities(ctx context.Context, accessToken string) error {
+	fetched, err := fetchActivitiesFromAPI(ctx, accessToken, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	// Halaman Strava bisa tumpang tindih jika aktivitas bertambah selama paginasi; versi terakhir yang dilihat dipakai
+	allActivities := mergeActivities(nil, fetched)
+	if duplicates := len(fetched) - len(allActivities); duplicates > 0 {
+		slog.WarnContext(ctx, "Aktivitas ganda dari Strava dibuang", "duplicate_count", duplicates)
+	}
+
+	activitiesFileMutex.Lock()
+	err = saveActivitiesFile(allActivities)
+	if err == nil {
+		// Sinkronisasi penuh mencakup seluruh riwayat atlet
+		err = saveCacheCoverage(cacheCoverage{})
+	}
+	activitiesFileMutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Sinkronisasi selesai", "activity_count", len(allActivities), "path", dataFilePath)
+	return nil
+}
+
+// fetchAndMergeNewActivities melakukan sinkronisasi inkremental: hanya aktivitas yang dimulai
+// setelah start_date terbaru di cache yang diambil, lalu digabung ke cache tanpa duplikasi ID.
+// Jika cache belum ada, fungsi ini jatuh kembali ke sinkronisasi penuh.
+func fetchAndMergeNewActivities(ctx context.Context, accessToken string) error {
+	if _, _, err := statActivitiesFile(); os.IsNotExist(err) {
+		slog.InfoContext(ctx, "Cache belum ada. Sinkronisasi inkremental diganti dengan sinkronisasi penuh.")
+		return fetchAndSaveAllActivities(ctx, accessToken)
+	}
+
+	activitiesFileMutex.Lock()
+	defer activitiesFileMutex.Unlock()
+
+	existing, err := readRawActivities()
+	if err != nil {
+		return err
+	}
+
+	after := latestStartDate(existing)
+	newActivities, err := fetchActivitiesFromAPI(ctx, accessToken, after.Unix(), 0)
+	if err != nil {
+		return err
+	}
+
+	merged := mergeActivities(existing, newActivities)
+	if err := saveActivitiesFile(merged); err != nil {
+		return err
+	}
+	// Cache kini mencakup hingga saat ini, termasuk jika sebelumnya hanya berisi rentang ?before=
+	coverage, err := loadCacheCoverage()
+	if err != nil {
+		return err
+	}
+	if err := saveCacheCoverage(coverage.extend(syncWindow{after: after})); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Sinkronisasi inkremental selesai",
+		"new_activity_count", len(newActivities),
+		"activity_count", len(merged),
+		"path", dataFilePath)
+	return nil
+}
+
+// runAutoSync menjalankan sinkronisasi inkremental setiap interval hingga ctx dibatalkan.
+// Tick dilewati jika belum ada token (pengguna belum login) atau batas rate Strava masih berlaku
+// dari kegagalan sebelumnya, sehingga sinkronisasi otomatis tidak menambah request saat dibatasi.
+func (s *Server) runAutoSync(ctx context.Context, interval time.Duration) {
+	slog.Info("Sinkronisasi otomatis aktif", "interval", interval.String())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pausedUntil time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Sinkronisasi otomatis dihentikan")
+			return
+		case <-ticker.C:
+		}
+
+		if now := s.clock.Now(); now.Before(pausedUntil) {
+			slog.Info("Sinkronisasi otomatis dilewati: batas rate Strava belum di-reset", "reset_at", pausedUntil.Format(time.RFC3339))
+			continue
+		}
+
+		if _, _, err := tokenSnapshot(s.clock.Now()); err != nil {
+			slog.DebugContext(ctx, "Sinkronisasi otomatis dilewati: belum ada token", "error", err)
+			continue
+		}
+		accessToken, err := s.ensureValidToken(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "Sinkronisasi otomatis dilewati: token tidak valid", "error", err)
+			continue
+		}
+
+		err = fetchAndMergeNewActivities(ctx, accessToken)
+		if err == nil {
+			err = syncVelocityStreams(ctx, accessToken)
+		}
+		if err != nil {
+			var rateLimitErr *RateLimitError
+			if errors.As(err, &rateLimitErr) {
+				pausedUntil = rateLimitErr.ResetAt
+			}
+			if ctx.Err() == nil {
+				slog.WarnContext(ctx, "Sinkronisasi otomatis gagal", "error", err)
+			}
+		}
+	}
+}
+
+// fetchAndMergeActivityWindow mengambil hanya aktivitas dalam rentang window lalu menggabungkannya
+// ke cache. Aktivitas cache di dalam rentang diganti seluruhnya oleh hasil Strava (sehingga aktivitas
+// yang sudah dihapus ikut hilang); aktivitas di luar rentang tidak disentuh.
+func fetchAndMergeActivityWindow(ctx context.Context, accessToken string, window syncWindow) error {
+	var after, before int64
+	if !window.after.IsZero() {
+		after = window.after.Unix()
+	}
+	if !window.before.IsZero() {
+		before = window.before.Unix()
+	}
+	fetched, err := fetchActivitiesFromAPI(ctx, accessToken, after, before)
+	if err != nil {
+		return err
+	}
+
+	activitiesFileMutex.Lock()
+	defer activitiesFileMutex.Unlock()
+
+	// Tanpa cache, yang tercakup hanya window itu sendiri (bukan seluruh riwayat sebelum/sesudahnya)
+	var existing []map[string]interface{}
+	coverage := cacheCoverage{CoveredFrom: window.after, CoveredUntil: window.before}
+	if _, _, err := statActivitiesFile(); err == nil {
+		if existing, err = readRawActivities(); err != nil {
+			return err
+		}
+		if coverage, err = loadCacheCoverage(); err != nil {
+			return err
+		}
+		coverage = coverage.extend(window)
+	}
+
+	outside := make([]map[string]interface{}, 0, len(existing))
+	for _, activity := range existing {
+		startDate, _ := activity["start_date"].(string)
+		t, err := time.Parse(time.RFC3339, startDate)
+		if err == nil && window.contains(t) {
+			continue
+		}
+		outside = append(outside, activity)
+	}
+
+	merged := mergeActivities(outside, fetched)
+	if err := saveActivitiesFile(merged); err != nil {
+		return err
+	}
+	if err := saveCacheCoverage(coverage); err != nil {
+		return err
+	}
+
+	slog.InfoContext(ctx, "Sinkronisasi rentang tanggal selesai",
+		"window_activity_count", len(fetched),
+		"activity_count", len(merged),
+		"path", dataFilePath)
+	return nil
+}
+
+// cacheCoverage mencatat rentang waktu yang benar-benar tercakup cache aktivitas (cache_meta.json),
+// sehingga rentang yang belum pernah disinkronkan tidak dianggap "tanpa aktivitas".
+// File meta yang tidak ada (mis. cache dari versi lama) berarti seluruh riwayat atlet sudah tersinkron.
+type cacheCoverage struct {
+	// CoveredFrom adalah start_date paling awal yang tercakup cache. Zero berarti sejak awal riwayat.
+	CoveredFrom time.Time `json:"covered_from,omitzero"`
+	// CoveredUntil adalah batas akhir (eksklusif) yang tercakup, diisi setelah sinkronisasi ?before=
+	// tanpa cache. Zero berarti hingga sinkronisasi terakhir; sinkronisasi inkremental mengosongkannya.
+	CoveredUntil time.Time `json:"covered_until,omitzero"`
+}
+
+// extend mengembalikan cakupan setelah window disinkronkan ke cache. Cakupan hanya diperluas
+// jika window bersambung dengan cakupan saat ini, agar tidak ada celah yang tercatat tercakup.
+func (cov cacheCoverage) extend(window syncWindow) cacheCoverage {
+	if !cov.CoveredUntil.IsZero() && !window.after.IsZero() && window.after.After(cov.CoveredUntil) {
+		return cov
+	}
+	if !cov.CoveredFrom.IsZero() && !window.before.IsZero() && window.before.Before(cov.CoveredFrom) {
+		return cov
+	}
+
+	extended := cov
+	if !cov.CoveredFrom.IsZero() && (window.after.IsZero() || window.after.Before(cov.CoveredFrom)) {
+		extended.CoveredFrom = window.after
+	}
+	if !cov.CoveredUntil.IsZero() && (window.before.IsZero() || window.before.After(cov.CoveredUntil)) {
+		extended.CoveredUntil = window.before
+	}
+	return extended
+}
+
+// covers melaporkan apakah cache mencakup semua aktivitas yang dimulai dalam [from, until).
+func (cov cacheCoverage) covers(from, until time.Time) bool {
+	if !cov.CoveredFrom.IsZero() && from.Before(cov.CoveredFrom) {
+		return false
+	}
+	return cov.CoveredUntil.IsZero() || !until.After(cov.CoveredUntil)
+}
+
+// loadCacheCoverage membaca cache_meta.json. Pemanggil harus memegang activitiesFileMutex.
+func loadCacheCoverage() (cacheCoverage, error) {
+	var coverage cacheCoverage
+	data, err := dataFS.ReadFile(cacheMetaFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return coverage, nil
+		}
+		return coverage, fmt.Errorf("gagal membaca metadata cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &coverage); err != nil {
+		return coverage, fmt.Errorf("gagal mengurai metadata cache: %w", err)
+	}
+	return coverage, nil
+}
+
+// saveCacheCoverage menulis cache_meta.json. Pemanggil harus memegang activitiesFileMutex.
+func saveCacheCoverage(coverage cacheCoverage) error {
+	data, err := json.MarshalIndent(coverage, "", " ")
+	if err != nil {
+		return fmt.Errorf("gagal marshal metadata cache: %w", err)
+	}
+	if err := dataFS.WriteFile(cacheMetaFilePath, data, 0644); err != nil {
+		return fmt.Errorf("gagal menulis metadata cache: %w", err)
+	}
+	return nil
+}
+
+// markCacheCoverage memastikan cache mencakup aktivitas pada tanggal start hingga end (inklusif).
+// Jika tidak dan access token masih berlaku, rentang yang kurang di-backfill dari Strava sebelum
+// statistik dihitung. Token yang kedaluwarsa tidak di-refresh dan backfill yang gagal tidak menggagalkan
+// request, sehingga statistik tetap bekerja offline; jika rentang tetap tidak tercakup, respons diberi
+// header X-Cache-Incomplete: true beserta X-Cache-Covered-From/X-Cache-Covered-Until, lalu true
+// dikembalikan agar handler dapat menandai responsnya.
+func (s *Server) markCacheCoverage(c *gin.Context, start, end time.Time) (cacheCoverage, bool) {
+	ctx := c.Request.Context()
+	// Rentang statistik memakai start_date_local; lebarkan satu hari di kedua sisi agar semua zona waktu tercakup
+	from, until := start.AddDate(0, 0, -1), end.AddDate(0, 0, 2)
+
+	activitiesFileMutex.Lock()
+	coverage, err := loadCacheCoverage()
+	activitiesFileMutex.Unlock()
+	if err != nil {
+		slog.WarnContext(ctx, "Gagal membaca metadata cache", "error", err)
+		return coverage, false
+	}
+	if coverage.covers(from, until) {
+		return coverage, false
+	}
+
+	if accessToken, needsRefresh, err := tokenSnapshot(s.clock.Now()); err == nil && !needsRefresh {
+		if backfilled, err := backfillCacheCoverage(ctx, accessToken, from, until); err != nil {
+			slog.WarnContext(ctx, "Backfill cache gagal. Statistik dihitung dari cache yang ada.", "error", err)
+		} else {
+			coverage = backfilled
+		}
+		if coverage.covers(from, until) {
+			return coverage, false
+		}
+	}
+
+	c.Header("X-Cache-Incomplete", "true")
+	if !coverage.CoveredFrom.IsZero() {
+		c.Header("X-Cache-Covered-From", coverage.CoveredFrom.Format(time.RFC3339))
+	}
+	if !coverage.CoveredUntil.IsZero() {
+		c.Header("X-Cache-Covered-Until", coverage.CoveredUntil.Format(time.RFC3339))
+	}
+	return coverage, true
+}
+
+// cacheBackfillMutex menyerialkan backfill agar request bersamaan tidak mengambil rentang yang sama berulang kali.
+var cacheBackfillMutex sync.Mutex
+
+// backfillCacheCoverage mengambil dari Strava bagian [from, until) yang berada di luar cakupan cache
+// (sebelum covered_from dan/atau setelah covered_until) lalu mengembalikan cakupan setelahnya.
+func backfillCacheCoverage(ctx context.Context, accessToken string, from, until time.Time) (cacheCoverage, error) {
+	cacheBackfillMutex.Lock()
+	defer cacheBackfillMutex.Unlock()
+
+	// Periksa ulang: request lain mungkin sudah mengisi rentang ini selama kita menunggu.
+	activitiesFileMutex.Lock()
+	coverage, err := loadCacheCoverage()
+	activitiesFileMutex.Unlock()
+	if err != nil {
+		return coverage, err
+	}
+
+	var windows []syncWindow
+	if !coverage.CoveredFrom.IsZero() && from.Before(coverage.CoveredFrom) {
+		windows = append(windows, syncWindow{after: from, before: coverage.CoveredFrom})
+	}
+	if !coverage.CoveredUntil.IsZero() && until.After(coverage.CoveredUntil) {
+		windows = append(windows, syncWindow{after: coverage.CoveredUntil, before: until})
+	}
+	for _, window := range windows {
+		slog.InfoContext(ctx, "Rentang di luar cakupan cache. Mengambil dari Strava...",
+			"after", window.after.Format(time.RFC3339), "before", window.before.Format(time.RFC3339))
+		if err := fetchAndMergeActivityWindow(ctx, accessToken, window); err != nil {
+			return coverage, err
+		}
+	}
+
+	activitiesFileMutex.Lock()
+	defer activitiesFileMutex.Unlock()
+	return loadCacheCoverage()
+}
+
+// fetchActivitiesFromAPI mengambil semua halaman aktivitas atlet dari Strava.
+// Jika after > 0, hanya aktivitas yang dimulai setelah epoch tersebut yang diambil;
+// jika before > 0, hanya aktivitas yang dimulai sebelum epoch tersebut.
+// Sinkronisasi dihentikan jika ctx dibatalkan (klien terputus atau server dimatikan), termasuk
+// saat sedang menunggu reset rate limit.
+func fetchActivitiesFromAPI(ctx context.Context, accessToken string, after, before int64) ([]map[string]interface{}, error) {
+	var allActivities []map[string]interface{}
+	perPage := stravaPerPage
+
+	for page := 1; ; page++ {
+		// Pengaman: hentikan sinkronisasi alih-alih berulang tanpa akhir jika Strava terus mengirim halaman penuh
+		if page > stravaMaxPages {
+			return nil, fmt.Errorf("sinkronisasi dihentikan: melebihi STRAVA_MAX_PAGES (%d halaman)", stravaMaxPages)
+		}
+
+		currentActivities, err := fetchActivitiesPage(ctx, accessToken, page, perPage, after, before)
+		if err != nil {
+			return nil, err
+		}
+
+		allActivities = append(allActivities, currentActivities...)
+
+		// Log kemajuan
+		slog.DebugContext(ctx, "Halaman aktivitas diambil", "page", page, "activity_count", len(currentActivities))
+
+		// Cek kondisi berhenti: jika kurang dari perPage, berarti ini adalah halaman terakhir
+		if len(currentActivities) < perPage {
+			break
+		}
+	}
+
+	return allActivities, nil
+}
+
+// fetchActivitiesPage mengambil satu halaman aktivitas dari Strava.
+func fetchActivitiesPage(ctx context.Context, accessToken string, page, perPage int, after, before int64) ([]map[string]interface{}, error) {
+	params := url.Values{}
+	params.Set("per_page", strconv.Itoa(perPage))
+	params.Set("page", strconv.Itoa(page))
+	if after > 0 {
+		params.Set("after", strconv.FormatInt(after, 10))
+	}
+	if before > 0 {
+		params.Set("before", strconv.FormatInt(before, 10))
+	}
+	activitiesURL := "https://www.strava.com/api/v3/athlete/activities?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, activitiesURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat request: %w", err)
+	}
+	// Gunakan access token yang valid
+	req.Header.Add("Authorization", "Bearer "+accessToken)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := stravaClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("gagal mengambil aktivitas dari Strava (Timeout/Network Error): %w", err)
+		}
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			rateLimitErr := parseRateLimitError(resp.Header, time.Now())
+			resp.Body.Close()
+
+			// Batas harian tidak masuk akal untuk ditunggu; kembalikan error agar frontend menampilkan waktu reset.
+			if rateLimitErr.Daily || attempt >= maxRateLimitRetries {
+				return nil, rateLimitErr
+			}
+
+			wait := rateLimitRetryDelay
+			if wait <= 0 {
+				wait = time.Until(rateLimitErr.ResetAt)
+			}
+			slog.WarnContext(ctx, "Rate limit Strava tercapai. Menunggu sebelum mencoba ulang...",
+				"page", page,
+				"usage", rateLimitErr.Usage,
+				"limit", rateLimitErr.Limit,
+				"wait", wait.Round(time.Second).String(),
+				"attempt", attempt+1,
+				"max_retries", maxRateLimitRetries)
+			// Penantian bisa sampai 15 menit; jangan menahan shutdown atau request yang sudah dibatalkan
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		return decodeActivitiesResponse(resp)
+	}
+}
+
+// decodeActivitiesResponse mengurai respons halaman aktivitas dan menutup body-nya.
+func decodeActivitiesResponse(resp *http.Response) ([]map[string]interface{}, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API Strava error: %s - Body: %s", resp.Status, bodyBytes)
+	}
+
+	var activities []map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&activities); err != nil {
+		return nil, fmt.Errorf("gagal mengurai respons Strava: %w", err)
+	}
+
+	return activities, nil
+}
+
+// RateLimitError menandakan Strava menolak request karena batas rate terlampaui.
+type RateLimitError struct {
+	Usage   string    // Nilai header X-RateLimit-Usage ("15menit,harian")
+	Limit   string    // Nilai header X-RateLimit-Limit ("15menit,harian")
+	Daily   bool      // true jika batas harian yang terlampaui
+	ResetAt time.Time // Perkiraan waktu batas di-reset oleh Strava
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("batas rate Strava terlampaui (usage %s / limit %s), reset pada %s", e.Usage, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// parseRateLimitError membaca header rate limit Strava dan menentukan kapan batas di-reset.
+// Strava me-reset batas 15 menit pada kelipatan 15 menit (UTC) dan batas harian pada tengah malam UTC.
+func parseRateLimitError(header http.Header, now time.Time) *RateLimitError {
+	rateLimitErr := &RateLimitError{
+		Usage: header.Get("X-RateLimit-Usage"),
+		Limit: header.Get("X-RateLimit-Limit"),
+	}
+
+	usage := parseRateLimitPair(rateLimitErr.Usage)
+	limit := parseRateLimitPair(rateLimitErr.Limit)
+	if usage[1] > 0 && limit[1] > 0 && usage[1] >= limit[1] {
+		rateLimitErr.Daily = true
+	}
+
+	now = now.UTC()
+	if rateLimitErr.Daily {
+		rateLimitErr.ResetAt = time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	} else {
+		rateLimitErr.ResetAt = now.Truncate(15 * time.Minute).Add(15 * time.Minute)
+	}
+
+	return rateLimitErr
+}
+
+// parseRateLimitPair mengurai nilai header "a,b" menjadi [a, b]. Nilai yang tidak valid menjadi 0.
+func parseRateLimitPair(value string) [2]int {
+	var pair [2]int
+	parts := strings.Split(value, ",")
+	for i := 0; i < len(parts) && i < 2; i++ {
+		n, err := strconv.Atoi(strings.TrimSpace(parts[i]))
+		if err == nil {
+			pair[i] = n
+		}
+	}
+	return pair
+}
+
+// saveActivitiesFile menulis seluruh aktivitas ke file cache lokal.
+func saveActivitiesFile(activities []map[string]interface{}) error {
+	// Buat folder data jika belum ada
+	if err := dataFS.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("gagal membuat direktori data: %w", err)
+	}
+
+	data, err := json.MarshalIndent(activities, "", " ") // Agar file JSON mudah dibaca (zcat)
+	if err != nil {
+		return fmt.Errorf("gagal marshal aktivitas: %w", err)
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return fmt.Errorf("gagal mengompresi aktivitas: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("gagal mengompresi aktivitas: %w", err)
+	}
+
+	err = dataFS.WriteFile(dataFilePath, compressed.Bytes(), 0644)
+	// Cache memori dibuang meskipun penulisan gagal, karena rename mungkin sudah terjadi
+	invalidateActivityCache()
+	if err != nil {
+		return fmt.Errorf("gagal menulis ke file JSON: %w", err)
+	}
+
+	// Migrasi: file lama tanpa kompresi tidak lagi dibaca setelah file gzip ada
+	if err := dataFS.Remove(legacyDataFilePath); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Gagal menghapus cache aktivitas lama tanpa kompresi", "path", legacyDataFilePath, "error", err)
+	}
+
+	return nil
+}
+
+// statActivitiesFile mengembalikan path dan info file cache aktivitas: file gzip, atau file lama
+// tanpa kompresi jika file gzip belum ada. Jika keduanya tidak ada, error memenuhi os.IsNotExist.
+func statActivitiesFile() (string, os.FileInfo, error) {
+	info, err := dataFS.Stat(dataFilePath)
+	if !os.IsNotExist(err) {
+		return dataFilePath, info, err
+	}
+	if legacyInfo, legacyErr := dataFS.Stat(legacyDataFilePath); legacyErr == nil {
+		return legacyDataFilePath, legacyInfo, nil
+	}
+	return dataFilePath, nil, err
+}
+
+// readActivitiesFile membaca isi JSON cache aktivitas, mendekompresi file gzip atau membaca file lama
+// tanpa kompresi jika file gzip belum ada. File gzip dibaca lebih dulu karena saveActivitiesFile menulisnya
+// sebelum menghapus file lama, sehingga migrasi yang berjalan bersamaan tidak menghasilkan "file tidak ada".
+func readActivitiesFile() ([]byte, error) {
+	compressed, err := dataFS.ReadFile(dataFilePath)
+	if os.IsNotExist(err) {
+		if legacy, legacyErr := dataFS.ReadFile(legacyDataFilePath); !os.IsNotExist(legacyErr) {
+			return legacy, legacyErr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("gagal mendekompresi file cache: %w", err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mendekompresi file cache: %w", err)
+	}
+	return data, nil
+}
+
+// openActivitiesFile membuka cache aktivitas di disk untuk dibaca sebagai JSON (didekompresi jika gzip).
+func openActivitiesFile() (io.ReadCloser, error) {
+	path, _, err := statActivitiesFile()
+	if err != nil {
+		return nil, err
+	}
+	file, err := dataFS.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if path == legacyDataFilePath {
+		return file, nil
+	}
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("gagal mendekompresi file cache: %w", err)
+	}
+	return gzipFileReader{Reader: zr, file: file}, nil
+}
+
+// gzipFileReader menutup reader gzip beserta file di bawahnya.
+type gzipFileReader struct {
+	*gzip.Reader
+	file io.Closer
+}
+
+func (r gzipFileReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+// writeFileAtomic menulis data ke file sementara unik "<path>.*.tmp" di direktori yang sama lalu
+// me-rename-nya ke path. Rename bersifat atomik pada filesystem yang sama, sehingga crash di tengah
+// penulisan tidak pernah merusak file lama, dan nama unik mencegah dua penulis path yang sama
+// (mis. dari goroutine berbeda) menulis ke file sementara yang sama.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	// CreateTemp selalu membuat file dengan mode 0600, sehingga di-Chmod ke perm sebelum data ditulis.
+	// Dengan begitu isi file (mis. token) tidak pernah terlihat dengan izin yang lebih longgar dari perm,
+	// dan rename membawa mode yang tepat.
+	if err := file.Chmod(perm); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// fileSystem adalah operasi file yang dipakai semua fungsi yang membaca atau menulis direktori data
+// (token, cache aktivitas, metadata cache, split, stream, gear, goals, dan klasifikasi), sehingga
+// fungsi tersebut dapat diuji tanpa file sungguhan dengan mengganti dataFS.
+type fileSystem interface {
+	ReadFile(name string) ([]byte, error)
+	Open(name string) (io.ReadCloser, error)
+	// WriteFile harus atomik: pembaca tidak pernah melihat file yang setengah ditulis.
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	Stat(name string) (os.FileInfo, error)
+	MkdirAll(path string, perm os.FileMode) error
+	Remove(name string) error
+	// ReadDir mengembalikan entri direktori yang diurutkan berdasarkan nama.
+	ReadDir(name string) ([]os.DirEntry, error)
+	// RemoveAll menghapus path beserta isinya. Path yang tidak ada bukan error.
+	RemoveAll(path string) error
+	Rename(oldpath, newpath string) error
+}
+
+// dataFS adalah filesystem yang dipakai semua akses ke direktori data.
+var dataFS fileSystem = osFileSystem{}
+
+// osFileSystem meneruskan operasi ke disk; WriteFile memakai writeFileAtomic.
+type osFileSystem struct{}
+
+func (osFileSystem) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+func (osFileSystem) Open(name string) (io.ReadCloser, error) { return os.Open(name) }
+
+func (osFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return writeFileAtomic(name, data, perm)
+}
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+func (osFileSystem) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+
+func (osFileSystem) Remove(name string) error { return os.Remove(name) }
+
+func (osFileSystem) ReadDir(name string) ([]os.DirEntry, error) { return os.ReadDir(name) }
+
+func (osFileSystem) RemoveAll(path string) error { return os.RemoveAll(path) }
+
+func (osFileSystem) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+
+// latestStartDate mengembalikan start_date paling akhir di antara aktivitas mentah.
+// Mengembalikan zero time jika tidak ada tanggal yang valid.
+func latestStartDate(activities []map[string]interface{}) time.Time {
+	var latest time.Time
+	for _, activity := range activities {
+		startDate, ok := activity["start_date"].(string)
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, startDate)
+		if err != nil {
+			continue
+		}
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// mergeActivities menggabungkan aktivitas baru ke aktivitas yang sudah ada berdasarkan `id`.
+// Aktivitas baru dengan ID yang sama menggantikan versi lama (mis. aktivitas yang diedit),
+// sehingga hasilnya tidak pernah berisi ID ganda. Hasil diurutkan berdasarkan start_date (naik);
+// slice masukan tidak diubah.
+func mergeActivities(existing, fetched []map[string]interface{}) []map[string]interface{} {
+	indexByID := make(map[int64]int, len(existing)+len(fetched))
+	merged := make([]map[string]interface{}, 0, len(existing)+len(fetched))
+
+	for _, batch := range [][]map[string]interface{}{existing, fetched} {
+		for _, activity := range batch {
+			id, ok := getFloat(activity["id"])
+			if !ok {
+				merged = append(merged, activity)
+				continue
+			}
+			if i, seen := indexByID[int64(id)]; seen {
+				merged[i] = activity
+				continue
+			}
+			indexByID[int64(id)] = len(merged)
+			merged = append(merged, activity)
+		}
+	}
+
+	// start_date selalu RFC3339 UTC (sufiks Z), sehingga urutan string sama dengan urutan waktu
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, _ := merged[i]["start_date"].(string)
+		b, _ := merged[j]["start_date"].(string)
+		return a < b
+	})
+
+	return merged
+}
+
+// parsePeriodQuery membaca ?year=YYYY atau ?month=YYYY-MM dan mengembalikan prefix periode
+// untuk calculateMonthlyDistanceStats ("" jika tidak ada filter).
+// Mengembalikan false (dan sudah mengirim respons 400) jika format tidak valid.
+func parsePeriodQuery(c *gin.Context) (string, bool) {
+	year := c.Query("year")
+	month := c.Query("month")
+
+	switch {
+	case year != "" && month != "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "period_conflict")})
+		return "", false
+	case year != "":
+		if _, err := time.Parse("2006", year); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_year")})
+			return "", false
+		}
+		return year, true
+	case month != "":
+		if _, err := time.Parse("2006-01", month); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_month")})
+			return "", false
+		}
+		return month, true
+	}
+
+	return "", true
+}
+
+// statsFilter menentukan aktivitas mana yang ikut dihitung oleh endpoint statistik.
+type statsFilter struct {
+	includePrivate bool // false: aktivitas private tidak dihitung (?include_private=false)
+	includeCommute bool // false: aktivitas commute tidak dihitung (?include_commute=false)
+}
+
+// defaultStatsFilter menghitung semua aktivitas.
+var defaultStatsFilter = statsFilter{includePrivate: true, includeCommute: true}
+
+// includesAll melaporkan apakah filter meloloskan semua aktivitas.
+func (f statsFilter) includesAll() bool {
+	return f.includePrivate && f.includeCommute
+}
+
+// parseStatsFilter membaca ?include_private=true|false dan ?include_commute=true|false (keduanya bawaan true).
+// Mengembalikan false (dan sudah mengirim respons 400) jika nilainya tidak valid.
+func parseStatsFilter(c *gin.Context) (statsFilter, bool) {
+	filter := defaultStatsFilter
+
+	if raw := c.Query("include_private"); raw != "" {
+		includePrivate, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_include_private")})
+			return filter, false
+		}
+		filter.includePrivate = includePrivate
+	}
+
+	if raw := c.Query("include_commute"); raw != "" {
+		includeCommute, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_include_commute")})
+			return filter, false
+		}
+		filter.includeCommute = includeCommute
+	}
+
+	return filter, true
+}
+
+// apply mengembalikan aktivitas yang lolos filter. Slice masukan tidak diubah.
+func (f statsFilter) apply(activities []StravaActivity) []StravaActivity {
+	if f.includesAll() {
+		return activities
+	}
+
+	filtered := make([]StravaActivity, 0, len(activities))
+	for _, activity := range activities {
+		if activity.Private && !f.includePrivate {
+			continue
+		}
+		if activity.Commute && !f.includeCommute {
+			continue
+		}
+		filtered = append(filtered, activity)
+	}
+	return filtered
+}
+
+// parseUnitsQuery membaca parameter ?units= (metric/imperial). Jika kosong, dianggap metric.
+// Mengembalikan false (dan sudah mengirim respons 400) jika nilainya tidak dikenal.
+func parseUnitsQuery(c *gin.Context) (string, bool) {
+	unit := c.DefaultQuery("units", unitMetric)
+	if unit != unitMetric && unit != unitImperial {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_units")})
+		return "", false
+	}
+	return unit, true
+}
+
+// convertDistance mengonversi jarak (meter) ke satuan yang diminta.
+// metric: tetap meter, imperial: mil.
+func convertDistance(meters float64, unit string) float64 {
+	if unit == unitImperial {
+		return meters / metersPerMile
+	}
+	return meters
+}
+
+// convertPace mengonversi pace (detik/meter) ke satuan yang diminta.
+// metric: tetap detik/meter, imperial: menit/mil.
+func convertPace(secPerMeter float64, unit string) float64 {
+	if unit == unitImperial {
+		return secPerMeter * metersPerMile / 60.0
+	}
+	return secPerMeter
+}
+
+// metersPer100Yards dipakai untuk pace renang imperial (detik/100 yard).
+const metersPer100Yards = 91.44
+
+// convertSwimPace mengonversi pace renang (detik/100 meter) ke satuan yang diminta.
+// metric: tetap detik/100 m, imperial: detik/100 yard.
+func convertSwimPace(secPer100m float64, unit string) float64 {
+	if unit == unitImperial {
+		return secPer100m * metersPer100Yards / 100
+	}
+	return secPer100m
+}
+
+// swimCategory adalah kategori khusus pace untuk renang. Untuk statistik jarak, renang tetap
+// termasuk Other (lihat classifyActivity); hanya /api/pace-stats yang memisahkannya.
+const swimCategory = "Swim"
+
+// paceCategory mengembalikan kategori pace untuk tipe Strava: "Swim" untuk renang (kecuali tipe
+// Swim dipetakan ulang di classification.json), selain itu sama dengan classifyActivity.
+func paceCategory(activityType string) string {
+	if _, overridden := classificationOverride(activityType); !overridden && activityType == "Swim" {
+		return swimCategory
+	}
+	return classifyActivity(activityType)
+}
+
+// paceCategories menentukan kategori yang pace-nya dihitung oleh /api/pace-stats (PACE_CATEGORIES).
+var paceCategories = defaultPaceCategories()
+
+// defaultPaceCategories mengaktifkan pace untuk semua kategori.
+func defaultPaceCategories() map[string]bool {
+	return map[string]bool{"RunWalkHike": true, "Bike": true, "Other": true, swimCategory: true}
+}
+
+// loadPaceCategories membaca PACE_CATEGORIES, daftar kategori dipisahkan koma
+// (mis. "RunWalkHike,Swim"). Kosong berarti semua kategori.
+func loadPaceCategories() (map[string]bool, error) {
+	raw := os.Getenv("PACE_CATEGORIES")
+	if strings.TrimSpace(raw) == "" {
+		return defaultPaceCategories(), nil
+	}
+
+	categories := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		category := strings.TrimSpace(part)
+		if !isActivityCategory(category) && category != swimCategory {
+			return nil, fmt.Errorf("PACE_CATEGORIES berisi kategori tidak dikenal %q. Gunakan RunWalkHike, Bike, Other, atau Swim", category)
+		}
+		categories[category] = true
+	}
+	return categories, nil
+}
+
+// runTypes adalah tipe Strava yang dihitung sebagai lari (RUN_TYPES): memakai batas zona pace lari
+// dan ikut dalam statistik khusus lari (rekor, HR, distribusi pace, mileage mingguan).
+var runTypes = defaultRunTypes()
+
+// defaultRunTypes mengembalikan tipe lari bawaan: Run, TrailRun, dan VirtualRun.
+func defaultRunTypes() map[string]bool {
+	return map[string]bool{"Run": true, "TrailRun": true, "VirtualRun": true}
+}
+
+// loadRunTypes membaca RUN_TYPES (tipe Strava dipisahkan koma, mis. "Run,TrailRun,VirtualRun").
+func loadRunTypes() (map[string]bool, error) {
+	raw := os.Getenv("RUN_TYPES")
+	if strings.TrimSpace(raw) == "" {
+		return defaultRunTypes(), nil
+	}
+
+	types := make(map[string]bool)
+	for _, part := range strings.Split(raw, ",") {
+		activityType := strings.TrimSpace(part)
+		if activityType == "" {
+			return nil, fmt.Errorf("RUN_TYPES berisi tipe kosong (%q)", raw)
+		}
+		types[activityType] = true
+	}
+	return types, nil
+}
+
+// isRunType melaporkan apakah tipe Strava dihitung sebagai lari.
+func isRunType(activityType string) bool {
+	return runTypes[activityType]
+}
+
+// classificationOverrides memetakan tipe Strava ke kategori, dimuat saat startup dari
+// classification.json dan dimuat ulang oleh POST /api/admin/recompute.
+// Tipe yang tidak ada di map memakai pemetaan bawaan. Akses dijaga classificationMutex.
+var (
+	classificationOverrides map[string]string
+	classificationMutex     sync.RWMutex
+)
+
+// classificationOverride mengembalikan kategori override untuk activityType, jika ada.
+func classificationOverride(activityType string) (string, bool) {
+	classificationMutex.RLock()
+	defer classificationMutex.RUnlock()
+	category, ok := classificationOverrides[activityType]
+	return category, ok
+}
+
+// setClassificationOverrides mengganti seluruh override klasifikasi.
+func setClassificationOverrides(overrides map[string]string) {
+	classificationMutex.Lock()
+	classificationOverrides = overrides
+	classificationMutex.Unlock()
+}
+
+// loadClassificationConfig membaca override klasifikasi dari classificationFilePath.
+// File yang belum ada bukan error (tidak ada override).
+func loadClassificationConfig() (map[string]string, error) {
+	data, err := dataFS.ReadFile(classificationFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var overrides map[string]string
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("gagal mengurai file klasifikasi: %w", err)
+	}
+	for activityType, category := range overrides {
+		if !isActivityCategory(category) {
+			return nil, fmt.Errorf("kategori %q untuk tipe %q tidak valid. Gunakan RunWalkHike, Bike, atau Other", category, activityType)
+		}
+	}
+	return overrides, nil
+}
+
+// isActivityCategory memeriksa apakah category adalah kategori hasil classifyActivity.
+func isActivityCategory(category string) bool {
+	return category == "RunWalkHike" || category == "Bike" || category == "Other"
+}
+
+// classifyActivity memetakan tipe Strava ke kategori RunWalkHike, Bike, atau Other.
+// Override dari classification.json didahulukan. Tanpa override, tipe lari (RUN_TYPES, mis. VirtualRun)
+// adalah RunWalkHike agar jaraknya masuk kategori yang sama dengan zona pace dan mileage mingguan.
+func classifyActivity(activityType string) string {
+	if category, ok := classificationOverride(activityType); ok {
+		return category
+	}
+	if isRunType(activityType) {
+		return "RunWalkHike"
+	}
+
+	switch activityType {
+	case "Run", "Walk", "Hike", "TrailRun":
+		return "RunWalkHike"
+	case "Ride", "VirtualRide", "Handcycle":
+		return "Bike"
+	default:
+		// Mencakup Swim, Yoga, AlpineSki, dll.
+		return "Other"
+	}
+}
+
+// activityCache menyimpan hasil parsing file cache aktivitas di memori (tanpa aktivitas yang
+// dibuang oleh dropImplausibleActivities).
+// Isi dimuat ulang jika file ditulis ulang oleh server (invalidateActivityCache)
+// atau jika mtime/ukuran file berubah (mis. diubah dari luar proses).
+var activityCache struct {
+	mu         sync.RWMutex
+	loaded     bool
+	modTime    time.Time
+	size       int64
+	activities []StravaActivity
+	rawCount   int // Jumlah record di file, termasuk yang dibuang dropImplausibleActivities
+}
+
+// getCachedActivities mengembalikan aktivitas dari cache memori, memuat ulang dari disk jika perlu.
+// Slice hasil dipakai bersama oleh semua pemanggil dan tidak boleh diubah.
+func getCachedActivities() ([]StravaActivity, error) {
+	activities, _, err := loadActivityCache()
+	return activities, err
+}
+
+// loadActivityCache memuat ulang cache memori jika perlu dan mengembalikan aktivitas beserta
+// jumlah record mentah di file.
+func loadActivityCache() ([]StravaActivity, int, error) {
+	_, info, err := statActivitiesFile()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, fmt.Errorf("file data lokal '%s' tidak ditemukan. Silakan sinkronisasi data dari Strava terlebih dahulu: %w", dataFilePath, err)
+		}
+		return nil, 0, fmt.Errorf("gagal membaca file data lokal: %w", err)
+	}
+
+	activityCache.mu.RLock()
+	if isActivityCacheFresh(info) {
+		activities, rawCount := activityCache.activities, activityCache.rawCount
+		activityCache.mu.RUnlock()
+		return activities, rawCount, nil
+	}
+	activityCache.mu.RUnlock()
+
+	activityCache.mu.Lock()
+	defer activityCache.mu.Unlock()
+
+	// Periksa ulang: goroutine lain mungkin sudah memuat ulang selama kita menunggu.
+	if isActivityCacheFresh(info) {
+		return activityCache.activities, activityCache.rawCount, nil
+	}
+
+	fileContent, err := readActivitiesFile()
+	if err != nil {
+		return nil, 0, fmt.Errorf("gagal membaca file data lokal: %w", err)
+	}
+	var activities []StravaActivity
+	if err := json.Unmarshal(fileContent, &activities); err != nil {
+		return nil, 0, fmt.Errorf("gagal mengurai file JSON: %w", err)
+	}
+	rawCount := len(activities)
+	// Filter dijalankan sekali per muat ulang, sehingga log aktivitas yang dibuang tidak berulang per request
+	activities = dropImplausibleActivities(activities)
+
+	activityCache.loaded = true
+	activityCache.modTime = info.ModTime()
+	activityCache.size = info.Size()
+	activityCache.activities = activities
+	activityCache.rawCount = rawCount
+	slog.Debug("Cache aktivitas dimuat ulang dari disk", "path", dataFilePath, "activity_count", len(activities))
+	return activities, rawCount, nil
+}
+
+// isActivityCacheFresh memeriksa apakah cache memori sesuai dengan info file. Pemanggil harus memegang activityCache.mu.
+func isActivityCacheFresh(info os.FileInfo) bool {
+	return activityCache.loaded && activityCache.modTime.Equal(info.ModTime()) && activityCache.size == info.Size()
+}
+
+// invalidateActivityCache memaksa getCachedActivities dan getCachedRawActivities membaca ulang file pada panggilan berikutnya.
+func invalidateActivityCache() {
+	activityCache.mu.Lock()
+	activityCache.loaded = false
+	activityCache.activities = nil
+	activityCache.mu.Unlock()
+
+	rawActivityCache.mu.Lock()
+	rawActivityCache.loaded = false
+	rawActivityCache.activities = nil
+	rawActivityCache.mu.Unlock()
+}
+
+// rawActivityCache adalah hasil urai readRawActivities untuk endpoint yang mengirim aktivitas apa adanya
+// (/api/activities dengan filter, recent, search, dan per ID). Seperti activityCache, cache dianggap
+// basi jika waktu modifikasi atau ukuran file berubah.
+var rawActivityCache struct {
+	mu         sync.RWMutex
+	loaded     bool
+	modTime    time.Time
+	size       int64
+	activities []map[string]interface{}
+}
+
+// getCachedRawActivities mengembalikan aktivitas mentah dari cache memori, memuat ulang dari disk jika perlu.
+// Slice dan map hasil dipakai bersama oleh semua pemanggil dan tidak boleh diubah.
+func getCachedRawActivities() ([]map[string]interface{}, error) {
+	_, info, err := statActivitiesFile()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file data lokal '%s' tidak ditemukan. Silakan sinkronisasi data dari Strava terlebih dahulu: %w", dataFilePath, err)
+		}
+		return nil, fmt.Errorf("gagal membaca file data lokal: %w", err)
+	}
+
+	rawActivityCache.mu.RLock()
+	if isRawActivityCacheFresh(info) {
+		activities := rawActivityCache.activities
+		rawActivityCache.mu.RUnlock()
+		return activities, nil
+	}
+	rawActivityCache.mu.RUnlock()
+
+	rawActivityCache.mu.Lock()
+	defer rawActivityCache.mu.Unlock()
+
+	// Periksa ulang: goroutine lain mungkin sudah memuat ulang selama kita menunggu.
+	if isRawActivityCacheFresh(info) {
+		return rawActivityCache.activities, nil
+	}
+
+	activities, err := readRawActivities()
+	if err != nil {
+		return nil, err
+	}
+	rawActivityCache.loaded = true
+	rawActivityCache.modTime = info.ModTime()
+	rawActivityCache.size = info.Size()
+	rawActivityCache.activities = activities
+	return activities, nil
+}
+
+// isRawActivityCacheFresh memeriksa apakah rawActivityCache sesuai dengan info file. Pemanggil harus memegang rawActivityCache.mu.
+func isRawActivityCacheFresh(info os.FileInfo) bool {
+	return rawActivityCache.loaded && rawActivityCache.modTime.Equal(info.ModTime()) && rawActivityCache.size == info.Size()
+}
+
+// readRawActivities membaca file cache lokal apa adanya (tanpa konversi tipe).
+func readRawActivities() ([]map[string]interface{}, error) {
+	fileContent, err := readActivitiesFile()
+	if err != nil {
+		// Periksa apakah error karena file tidak ditemukan.
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("file data lokal '%s' tidak ditemukan. Silakan sinkronisasi data dari Strava terlebih dahulu: %w", dataFilePath, err)
+		}
+		return nil, fmt.Errorf("gagal membaca file data lokal: %w", err)
+	}
+
+	var rawActivities []map[string]interface{}
+	if err := json.Unmarshal(fileContent, &rawActivities); err != nil {
+		return nil, fmt.Errorf("gagal mengurai file JSON: %w", err)
+	}
+
+	return rawActivities, nil
+}
+
+// readLocalActivities mengembalikan aktivitas dari cache memori yang lolos filter serta memiliki
+// tanggal, tipe, jarak, dan waktu bergerak. Cache yang terbaca tetapi tidak berisi aktivitas valid
+// (mis. akun Strava baru) menghasilkan slice kosong tanpa error; error hanya berarti file tidak
+// dapat dibaca atau diurai.
+func readLocalActivities(filter statsFilter) ([]MinimalActivityData, error) {
+	activities, err := getCachedActivities()
+	if err != nil {
+		return nil, err
+	}
+
+	minimalActivities := []MinimalActivityData{}
+	for _, activity := range filter.apply(activities) {
+		if activity.StartDate != "" && activity.Type != "" && activity.Distance > 0 && activity.MovingTime > 0 {
+			minimalActivities = append(minimalActivities, MinimalActivityData{
+				StartDate:          activity.StartDate,
+				StartDateLocal:     activity.StartDateLocal,
+				Distance:           activity.Distance,
+				MovingTime:         activity.MovingTime,
+				TotalElevationGain: activity.elevationGain(),
+				Type:               activity.Type,
+				KudosCount:         activity.KudosCount,
+				AchievementCount:   activity.AchievementCount,
+			})
+		}
+	}
+
+	return minimalActivities, nil
+}
+
+// validateActivityData memeriksa setiap record mentah dengan aturan yang sama seperti readLocalActivities
+// (ditambah parsing tanggal yang dilakukan fungsi statistik) dan menghitung record yang dilewati per alasan.
+func validateActivityData(rawActivities []map[string]interface{}) DataValidationReport {
+	report := DataValidationReport{
+		Total:           len(rawActivities),
+		SkippedByReason: make(map[string]int),
+		SkippedSamples:  []SkippedActivity{},
+	}
+
+	for _, activity := range rawActivities {
+		reason := activitySkipReason(activity)
+		if reason == "" {
+			report.Valid++
+			continue
+		}
+
+		report.Skipped++
+		report.SkippedByReason[reason]++
+		if len(report.SkippedSamples) < maxSkippedSamples {
+			name, _ := activity["name"].(string)
+			report.SkippedSamples = append(report.SkippedSamples, SkippedActivity{
+				ID:     activity["id"],
+				Name:   name,
+				Reason: reason,
+			})
+		}
+	}
+
+	return report
+}
+
+// activitySkipReason mengembalikan alasan record dilewati oleh statistik, atau "" jika valid.
+func activitySkipReason(activity map[string]interface{}) string {
+	startDate, ok := activity["start_date"].(string)
+	if !ok || startDate == "" {
+		return "missing_start_date"
+	}
+	if _, err := time.Parse(time.RFC3339, startDate); err != nil {
+		return "invalid_start_date"
+	}
+	activityType, ok := activity["type"].(string)
+	if !ok || activityType == "" {
+		return "missing_type"
+	}
+
+	distance, ok := getFloat(activity["distance"])
+	if !ok {
+		return "missing_distance"
+	}
+	if distance <= 0 {
+		return "non_positive_distance"
+	}
+
+	movingTime, ok := getFloat(activity["moving_time"])
+	if !ok {
+		return "missing_moving_time"
+	}
+	if movingTime <= 0 {
+		return "non_positive_moving_time"
+	}
+
+	if limit := maxSpeeds.maxSpeedFor(classifyActivity(activityType)); limit > 0 && distance/movingTime > limit {
+		return "implausible_speed"
+	}
+
+	return ""
+}
+
+// getFloat (Sama)
+func getFloat(v interface{}) (float64, bool) {
+	switch f := v.(type) {
+	case float64:
+		return f, true
+	case int:
+		return float64(f), true
+	case int64:
+		return float64(f), true
+	default:
+		return 0, false
+	}
+}
+
+// calculateMonthlyDistanceStats menghitung jarak per kategori per bulan.
+// period membatasi bulan yang dihitung: "" (semua), "YYYY" (satu tahun), atau "YYYY-MM" (satu bulan).
+func calculateMonthlyDistanceStats(filter statsFilter, period string) ([]MonthlySportStats, error) {
+	activities, err := readLocalActivities(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	statsMap := make(map[string]MonthlySportStats)
+
+	for _, activity := range activities {
+		// Bulan lokal (YYYY-MM)
+		monthYear, ok := activity.localMonth()
+		if !ok {
+			continue // Lewati jika gagal parse tanggal
+		}
+		if !strings.HasPrefix(monthYear, period) {
+			continue // Di luar periode yang diminta
+		}
+
+		// Klasifikasi
+		category := classifyActivity(activity.Type)
+
+		stat, exists := statsMap[monthYear]
+		if !exists {
+			stat.MonthYear = monthYear
+		}
+
+		// Tambahkan jarak (distance) ke kategori yang sesuai
+		switch category {
+		case "RunWalkHike":
+			stat.RunWalkHike += activity.Distance
+		case "Bike":
+			stat.Bike += activity.Distance
+		case "Other":
+			stat.Other += activity.Distance
+			if stat.OtherByType == nil {
+				stat.OtherByType = make(map[string]float64)
+			}
+			stat.OtherByType[activity.Type] += activity.Distance
+		}
+		stat.TotalElevationGain += activity.TotalElevationGain
+
+		statsMap[monthYear] = stat
+	}
+
+	// Konversi map menjadi slice
+	monthlyStats := make([]MonthlySportStats, 0, len(statsMap))
+	for _, stat := range statsMap {
+		monthlyStats = append(monthlyStats, stat)
+	}
+
+	// Urutkan kronologis; format YYYY-MM dapat diurutkan secara leksikografis
+	sort.Slice(monthlyStats, func(i, j int) bool {
+		return monthlyStats[i].MonthYear < monthlyStats[j].MonthYear
+	})
+
+	return monthlyStats, nil
+}
+
+// calculateYearlyDistanceStats mengakumulasi jarak per kategori untuk setiap tahun kalender.
+// Tahun tanpa aktivitas tidak muncul di hasil.
+func calculateYearlyDistanceStats(filter statsFilter) ([]YearlySportStats, error) {
+	activities, err := readLocalActivities(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	statsMap := make(map[string]YearlySportStats)
+
+	for _, activity := range activities {
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue // Lewati jika gagal parse tanggal
+		}
+		year := t.Format("2006") // Format YYYY
+
+		stat, exists := statsMap[year]
+		if !exists {
+			stat.Year = year
+		}
+
+		switch classifyActivity(activity.Type) {
+		case "RunWalkHike":
+			stat.RunWalkHike += activity.Distance
+		case "Bike":
+			stat.Bike += activity.Distance
+		case "Other":
+			stat.Other += activity.Distance
+		}
+
+		statsMap[year] = stat
+	}
+
+	yearlyStats := make([]YearlySportStats, 0, len(statsMap))
+	for _, stat := range statsMap {
+		yearlyStats = append(yearlyStats, stat)
+	}
+
+	sort.Slice(yearlyStats, func(i, j int) bool {
+		return yearlyStats[i].Year < yearlyStats[j].Year
+	})
+
+	return yearlyStats, nil
+}
+
+// calculateMonthlySocialStats menjumlahkan kudos dan achievement per bulan.
+// Bulan tanpa aktivitas tidak muncul di hasil.
+func calculateMonthlySocialStats(filter statsFilter) ([]MonthlySocialStats, error) {
+	activities, err := readLocalActivities(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	statsMap := make(map[string]MonthlySocialStats)
+
+	for _, activity := range activities {
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue // Lewati jika gagal parse tanggal
+		}
+		monthYear := t.Format("2006-01") // Format YYYY-MM
+
+		stat, exists := statsMap[monthYear]
+		if !exists {
+			stat.MonthYear = monthYear
+		}
+		stat.KudosCount += activity.KudosCount
+		stat.AchievementCount += activity.AchievementCount
+		stat.ActivityCount++
+
+		statsMap[monthYear] = stat
+	}
+
+	socialStats := make([]MonthlySocialStats, 0, len(statsMap))
+	for _, stat := range statsMap {
+		socialStats = append(socialStats, stat)
+	}
+
+	sort.Slice(socialStats, func(i, j int) bool {
+		return socialStats[i].MonthYear < socialStats[j].MonthYear
+	})
+
+	return socialStats, nil
+}
+
+// minRecordPaceDistance adalah jarak minimum (meter) agar sebuah lari dihitung untuk rekor pace tercepat.
+const minRecordPaceDistance = 1000.0
+
+// calculatePersonalRecords mencari rekor pribadi di antara aktivitas lari pada cache lokal.
+func calculatePersonalRecords(filter statsFilter) PersonalRecords {
+	var records PersonalRecords
+
+	for _, activity := range loadLocalActivities(filter) {
+		if !isRunType(activity.Type) || activity.Distance <= 0 || activity.MovingTime <= 0 {
+			continue
+		}
+
+		newRecord := func(value float64) *ActivityRecord {
+			return &ActivityRecord{
+				ActivityID: activity.ID,
+				Name:       activity.Name,
+				StartDate:  activity.StartDateLocal,
+				Value:      value,
+			}
+		}
+
+		// Lari di bawah 1 km diabaikan agar sprint pendek tidak mendominasi rekor pace
+		speed := activity.Distance / activity.MovingTime
+		if activity.Distance >= minRecordPaceDistance && (records.FastestPace == nil || speed > records.FastestPace.Value) {
+			records.FastestPace = newRecord(speed)
+		}
+
+		if records.LongestDistance == nil || activity.Distance > records.LongestDistance.Value {
+			records.LongestDistance = newRecord(activity.Distance)
+		}
+
+		if records.LongestMovingTime == nil || activity.MovingTime > records.LongestMovingTime.Value {
+			records.LongestMovingTime = newRecord(activity.MovingTime)
+		}
+	}
+
+	return records
+}
+
+// calculateMonthlyClimbStats menjumlahkan elevasi dan jarak per bulan untuk RunWalkHike dan Bike,
+// lalu menghitung laju tanjakan (meter per km). Aktivitas tanpa data elevasi dilewati agar tidak
+// dihitung sebagai aktivitas datar.
+func calculateMonthlyClimbStats(filter statsFilter) []MonthlyClimbStats {
+	statsMap := make(map[string]MonthlyClimbStats)
+
+	for _, activity := range loadLocalActivities(filter) {
+		if activity.TotalElevationGain == nil || activity.Distance <= 0 {
+			continue
+		}
+
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue
+		}
+		monthYear := t.Format("2006-01")
+
+		stat, exists := statsMap[monthYear]
+		if !exists {
+			stat.MonthYear = monthYear
+		}
+
+		var category *ClimbStat
+		switch classifyActivity(activity.Type) {
+		case "RunWalkHike":
+			category = &stat.RunWalkHike
+		case "Bike":
+			category = &stat.Bike
+		default:
+			continue
+		}
+		category.ElevationGain += *activity.TotalElevationGain
+		category.Distance += activity.Distance
+
+		statsMap[monthYear] = stat
+	}
+
+	monthlyStats := make([]MonthlyClimbStats, 0, len(statsMap))
+	for _, stat := range statsMap {
+		for _, category := range []*ClimbStat{&stat.RunWalkHike, &stat.Bike} {
+			if category.Distance > 0 {
+				category.ClimbRate = category.ElevationGain / (category.Distance / 1000.0)
+			}
+		}
+		monthlyStats = append(monthlyStats, stat)
+	}
+
+	sort.Slice(monthlyStats, func(i, j int) bool {
+		return monthlyStats[i].MonthYear < monthlyStats[j].MonthYear
+	})
+
+	return monthlyStats
+}
+
+// calculateGearStats menjumlahkan jarak (meter) dan jumlah aktivitas per gear_id,
+// diurutkan dari jarak terbesar. Aktivitas tanpa gear dikelompokkan sebagai "unassigned".
+func calculateGearStats(filter statsFilter) []GearStat {
+	statsMap := make(map[string]GearStat)
+
+	for _, activity := range loadLocalActivities(filter) {
+		gearID := activity.GearID
+		if gearID == "" {
+			gearID = unassignedGearID
+		}
+
+		stat := statsMap[gearID]
+		stat.GearID = gearID
+		stat.TotalDistance += activity.Distance
+		stat.ActivityCount++
+		statsMap[gearID] = stat
+	}
+
+	gearStats := make([]GearStat, 0, len(statsMap))
+	for _, stat := range statsMap {
+		gearStats = append(gearStats, stat)
+	}
+
+	sort.Slice(gearStats, func(i, j int) bool {
+		if gearStats[i].TotalDistance != gearStats[j].TotalDistance {
+			return gearStats[i].TotalDistance > gearStats[j].TotalDistance
+		}
+		return gearStats[i].GearID < gearStats[j].GearID
+	})
+
+	return gearStats
+}
+
+// calculateMonthlyHRStats mengelompokkan lari berdasarkan detak jantung rata-rata ke zona HR
+// dan menjumlahkan waktu bergerak per zona per bulan. Lari tanpa data HR dilewati.
+func calculateMonthlyHRStats(filter statsFilter) []MonthlyHRStats {
+	statsMap := make(map[string]MonthlyHRStats)
+
+	for _, activity := range loadLocalActivities(filter) {
+		if !isRunType(activity.Type) || activity.AverageHeartrate <= 0 {
+			continue
+		}
+
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue
+		}
+		monthYear := t.Format("2006-01")
+
+		stat, exists := statsMap[monthYear]
+		if !exists {
+			stat = MonthlyHRStats{
+				MonthYear:   monthYear,
+				ZoneSeconds: make([]float64, len(hrZoneBounds)+1),
+			}
+		}
+		stat.ZoneSeconds[getHRZoneIndex(activity.AverageHeartrate)] += activity.MovingTime
+
+		statsMap[monthYear] = stat
+	}
+
+	monthlyStats := make([]MonthlyHRStats, 0, len(statsMap))
+	for _, stat := range statsMap {
+		monthlyStats = append(monthlyStats, stat)
+	}
+
+	sort.Slice(monthlyStats, func(i, j int) bool {
+		return monthlyStats[i].MonthYear < monthlyStats[j].MonthYear
+	})
+
+	return monthlyStats
+}
+
+// calculateMonthlyEfficiency menjumlahkan moving_time dan elapsed_time per kategori per bulan
+// lalu menghitung rasionya. Aktivitas dengan elapsed_time nol dilewati.
+func calculateMonthlyEfficiency(filter statsFilter) []MonthlyEfficiencyStats {
+	statsMap := make(map[string]MonthlyEfficiencyStats)
+
+	for _, activity := range loadLocalActivities(filter) {
+		if activity.ElapsedTime <= 0 {
+			continue
+		}
+
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue
+		}
+		monthYear := t.Format("2006-01")
+
+		stat, exists := statsMap[monthYear]
+		if !exists {
+			stat.MonthYear = monthYear
+		}
+
+		var category *EfficiencyStat
+		switch classifyActivity(activity.Type) {
+		case "RunWalkHike":
+			category = &stat.RunWalkHike
+		case "Bike":
+			category = &stat.Bike
+		default:
+			category = &stat.Other
+		}
+		category.MovingTime += activity.MovingTime
+		category.ElapsedTime += activity.ElapsedTime
+
+		statsMap[monthYear] = stat
+	}
+
+	monthlyStats := make([]MonthlyEfficiencyStats, 0, len(statsMap))
+	for _, stat := range statsMap {
+		for _, category := range []*EfficiencyStat{&stat.RunWalkHike, &stat.Bike, &stat.Other} {
+			if category.ElapsedTime > 0 {
+				category.Ratio = category.MovingTime / category.ElapsedTime
+			}
+		}
+		monthlyStats = append(monthlyStats, stat)
+	}
+
+	sort.Slice(monthlyStats, func(i, j int) bool {
+		return monthlyStats[i].MonthYear < monthlyStats[j].MonthYear
+	})
+
+	return monthlyStats
+}
+
+// calculateLifetimeSummary menghitung total sepanjang masa dari cache lokal.
+// Cache yang belum ada atau kosong menghasilkan ringkasan bernilai nol, bukan error.
+func calculateLifetimeSummary(filter statsFilter) (LifetimeSummary, error) {
+	var summary LifetimeSummary
+
+	activities, err := readLocalActivities(filter)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return summary, nil
+		}
+		return summary, err
+	}
+
+	var first, last time.Time
+	for _, activity := range activities {
+		switch classifyActivity(activity.Type) {
+		case "RunWalkHike":
+			summary.RunWalkHike += activity.Distance
+		case "Bike":
+			summary.Bike += activity.Distance
+		case "Other":
+			summary.Other += activity.Distance
+		}
+		summary.TotalActivities++
+		summary.TotalMovingTime += activity.MovingTime
+
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue
+		}
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+	}
+
+	if !first.IsZero() {
+		summary.FirstActivityDate = first.Format(time.RFC3339)
+		summary.LastActivityDate = last.Format(time.RFC3339)
+	}
+
+	return summary, nil
+}
+
+// calculatePaceDistribution mengelompokkan pace rata-rata setiap lari ke bucket selebar paceBucketWidth
+// (detik per km). Bucket kosong di antara pace tercepat dan terlambat tetap disertakan agar histogram
+// kontinu. Hasil diurutkan dari pace tercepat.
+func calculatePaceDistribution(activities []StravaActivity) []PaceBucket {
+	bucketsByIndex := make(map[int]*PaceBucket)
+	minIndex, maxIndex := 0, -1
+
+	for _, activity := range activities {
+		if !isRunType(activity.Type) {
+			continue
+		}
+		speed, ok := averageSpeed(activity.Distance, activity.MovingTime)
+		if !ok {
+			continue
+		}
+
+		index := int(math.Floor(1000.0 / speed / paceBucketWidth))
+		bucket, exists := bucketsByIndex[index]
+		if !exists {
+			bucket = &PaceBucket{}
+			if len(bucketsByIndex) == 0 {
+				minIndex, maxIndex = index, index
+			}
+			bucketsByIndex[index] = bucket
+			minIndex = min(minIndex, index)
+			maxIndex = max(maxIndex, index)
+		}
+		bucket.Count++
+		bucket.TotalDistance += activity.Distance
+	}
+
+	distribution := make([]PaceBucket, 0, maxIndex-minIndex+1)
+	for index := minIndex; index <= maxIndex; index++ {
+		bucket := PaceBucket{}
+		if existing, ok := bucketsByIndex[index]; ok {
+			bucket = *existing
+		}
+		bucket.MinPace = float64(index) * paceBucketWidth
+		bucket.MaxPace = bucket.MinPace + paceBucketWidth
+		distribution = append(distribution, bucket)
+	}
+	return distribution
+}
+
+// calculateAvgWeeklyMileage menjumlahkan jarak lari (meter) per minggu untuk weeks minggu penuh
+// sebelum minggu berjalan (minggu dimulai pada weekStart), lalu membaginya dengan weeks.
+// Minggu tanpa lari tetap dihitung sebagai nol.
+func calculateAvgWeeklyMileage(filter statsFilter, now time.Time, weeks int) AvgWeeklyMileage {
+	// start_date_local ditulis Strava dengan sufiks Z, jadi tanggal hari ini (now di APP_TIMEZONE) juga dinyatakan dalam "UTC"
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	currentWeek := startOfWeek(today, weekStart)
+	firstWeek := currentWeek.AddDate(0, 0, -7*weeks)
+
+	mileage := AvgWeeklyMileage{Weeks: weeks, WeeklyTotals: make([]WeeklyMileage, weeks)}
+	for i := range mileage.WeeklyTotals {
+		mileage.WeeklyTotals[i].WeekStart = firstWeek.AddDate(0, 0, 7*i).Format("2006-01-02")
+	}
+
+	var total float64
+	for _, activity := range loadLocalActivities(filter) {
+		if !isRunType(activity.Type) {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, activity.StartDateLocal)
+		if err != nil || t.Before(firstWeek) || !t.Before(currentWeek) {
+			continue
+		}
+
+		index := int(t.Sub(firstWeek).Hours() / (24 * 7))
+		mileage.WeeklyTotals[index].Distance += activity.Distance
+		total += activity.Distance
+	}
+
+	mileage.AverageDistance = total / float64(weeks)
+	return mileage
+}
+
+// calculateAvailablePeriods mengumpulkan bulan (YYYY-MM) dan tahun (YYYY) unik dari aktivitas di cache,
+// dengan pengelompokan lokal (start_date_local) yang sama seperti /api/stats dan /api/yearly-stats.
+func calculateAvailablePeriods(filter statsFilter) AvailablePeriods {
+	months := make(map[string]bool)
+	years := make(map[string]bool)
+	for _, activity := range loadLocalActivities(filter) {
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue
+		}
+		years[t.Format("2006")] = true
+		months[t.Format("2006-01")] = true
+	}
+
+	periods := AvailablePeriods{
+		Months: make([]string, 0, len(months)),
+		Years:  make([]string, 0, len(years)),
+	}
+	for month := range months {
+		periods.Months = append(periods.Months, month)
+	}
+	for year := range years {
+		periods.Years = append(periods.Years, year)
+	}
+	sort.Strings(periods.Months)
+	sort.Strings(periods.Years)
+	return periods
+}
+
+// Metrik yang didukung oleh parameter ?metric= pada /api/calendar
+const (
+	calendarMetricDistance = "distance"
+	calendarMetricCount    = "count"
+)
+
+// calculateCalendar menjumlahkan jarak (meter) atau jumlah aktivitas per tanggal lokal (YYYY-MM-DD)
+// untuk aktivitas yang dimulai pada tahun year. Hari tanpa aktivitas tidak muncul di map.
+func calculateCalendar(activities []StravaActivity, year int, metric string) map[string]float64 {
+	calendar := make(map[string]float64)
+	for _, activity := range activities {
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok || t.Year() != year {
+			continue
+		}
+		day := t.Format("2006-01-02")
+		if metric == calendarMetricCount {
+			calendar[day]++
+		} else {
+			calendar[day] += activity.Distance
+		}
+	}
+	return calendar
+}
+
+// calculateWeekdayDistribution mengelompokkan aktivitas per hari dalam seminggu menurut tanggal lokal
+// (start_date_local). Hasil selalu berisi tujuh hari berurutan Senin-Minggu, termasuk hari tanpa aktivitas.
+func calculateWeekdayDistribution(activities []StravaActivity) []WeekdayStat {
+	distribution := make([]WeekdayStat, 7)
+	for i := range distribution {
+		// Indeks 0 = Senin; time.Weekday dimulai dari Minggu
+		distribution[i].Weekday = time.Weekday((i + 1) % 7).String()
+	}
+
+	for _, activity := range activities {
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue
+		}
+		i := (int(t.Weekday()) + 6) % 7
+		distribution[i].Count++
+		distribution[i].TotalDistance += activity.Distance
+	}
+	return distribution
+}
+
+// calculateYTDComparison menghitung jarak kumulatif per tanggal lokal (start_date_local) dari 1 Januari
+// hingga tanggal now, serta untuk tahun lalu hingga tanggal kalender yang sama. Setiap hari muncul di
+// seri (hari tanpa aktivitas membawa nilai kumulatif sebelumnya). Seri disejajarkan per tanggal, bukan
+// per nomor hari dalam tahun: jika hari ini 29 Februari dan tahun lalu bukan tahun kabisat, seri tahun
+// lalu berakhir di 28 Februari; sebaliknya 29 Februari tahun lalu ikut terhitung setelah tanggal itu lewat.
+func calculateYTDComparison(activities []StravaActivity, now time.Time) YTDComparison {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	sameDayLastYear := time.Date(today.Year()-1, today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if sameDayLastYear.Month() != today.Month() {
+		// 29 Februari dinormalisasi ke 1 Maret; mundur ke hari terakhir Februari
+		sameDayLastYear = time.Date(today.Year()-1, today.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+	}
+
+	daily := make(map[string]float64)
+	for _, activity := range activities {
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue
+		}
+		daily[t.Format("2006-01-02")] += activity.Distance
+	}
+
+	return YTDComparison{
+		AsOf:         today.Format("2006-01-02"),
+		CurrentYear:  today.Year(),
+		PreviousYear: today.Year() - 1,
+		Current:      cumulativeDailySeries(daily, today),
+		Previous:     cumulativeDailySeries(daily, sameDayLastYear),
+	}
+}
+
+// cumulativeDailySeries menjumlahkan jarak harian secara kumulatif dari 1 Januari tahun end hingga end.
+func cumulativeDailySeries(daily map[string]float64, end time.Time) []YTDPoint {
+	start := time.Date(end.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	series := make([]YTDPoint, 0, end.YearDay())
+	total := 0.0
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		total += daily[date]
+		series = append(series, YTDPoint{Date: date, Distance: total})
+	}
+	return series
+}
+
+// calculateStreaks menghitung streak terpanjang dan streak saat ini dari tanggal start_date_local
+// aktivitas hingga now. Beberapa aktivitas pada hari yang sama dihitung satu hari. Streak saat ini
+// tetap berjalan jika hari ini belum ada aktivitas tetapi kemarin ada (hari ini belum selesai).
+func calculateStreaks(filter statsFilter, now time.Time) StreakStats {
+	// start_date_local ditulis Strava dengan sufiks Z, jadi tanggal hari ini (now di APP_TIMEZONE) juga dinyatakan dalam "UTC"
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	stats := StreakStats{AsOf: today.Format("2006-01-02")}
+
+	activeDays := make(map[time.Time]bool)
+	for _, activity := range loadLocalActivities(filter) {
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue
+		}
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		if day.After(today) {
+			continue
+		}
+		activeDays[day] = true
+	}
+	stats.ActiveDays = len(activeDays)
+
+	days := make([]time.Time, 0, len(activeDays))
+	for day := range activeDays {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	run := 0
+	for i, day := range days {
+		if i > 0 && days[i-1].AddDate(0, 0, 1).Equal(day) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > stats.LongestStreak {
+			stats.LongestStreak = run
+			stats.LongestStreakStart = day.AddDate(0, 0, -(run - 1)).Format("2006-01-02")
+			stats.LongestStreakEnd = day.Format("2006-01-02")
+		}
+	}
+
+	day := today
+	if !activeDays[day] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for activeDays[day] {
+		stats.CurrentStreak++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return stats
+}
+
+// calculateRollingStats menjumlahkan jarak per kategori dalam 7, 30, dan 90 hari terakhir per now.
+// Jendela N hari mencakup hari ini dan N-1 hari sebelumnya, dibandingkan dengan start_date_local
+// (waktu lokal atlet) terhadap tanggal kalender now pada zona waktunya sendiri.
+func calculateRollingStats(filter statsFilter, now time.Time) RollingStats {
+	// start_date_local ditulis Strava dengan sufiks Z, jadi tanggal hari ini (now di APP_TIMEZONE) juga dinyatakan dalam "UTC"
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	endOfToday := today.AddDate(0, 0, 1)
+
+	stats := RollingStats{AsOf: today.Format("2006-01-02")}
+	for _, days := range rollingWindowDays {
+		stats.Windows = append(stats.Windows, RollingWindowStats{Days: days})
+	}
+
+	for _, activity := range loadLocalActivities(filter) {
+		t, ok := localStartTime(activity.StartDate, activity.StartDateLocal)
+		if !ok {
+			continue
+		}
+		// Bandingkan per tanggal kalender lokal, karena t bisa berada di APP_TIMEZONE (tanpa start_date_local)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		if !day.Before(endOfToday) {
+			continue
+		}
+
+		for i := range stats.Windows {
+			window := &stats.Windows[i]
+			if day.Before(today.AddDate(0, 0, -(window.Days - 1))) {
+				continue
+			}
+			switch classifyActivity(activity.Type) {
+			case "RunWalkHike":
+				window.RunWalkHike += activity.Distance
+			case "Bike":
+				window.Bike += activity.Distance
+			case "Other":
+				window.Other += activity.Distance
+			}
+		}
+	}
+
+	return stats
+}
+
+// calculateMonthlyPaceStats (Sama)
+func calculateMonthlyPaceStats(filter statsFilter) ([]MonthlyPaceStats, error) {
+	activities, err := readLocalActivities(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	paceMap := make(map[string]MonthlyPaceStats)
+
+	for _, activity := range activities {
+		monthYear, ok := activity.localMonth()
+		if !ok {
+			continue
+		}
+
+		// Klasifikasi (renang dipisahkan dari Other agar pace-nya dapat dihitung per 100 m)
+		category := paceCategory(activity.Type)
+
+		stat, exists := paceMap[monthYear]
+		if !exists {
+			stat.MonthYear = monthYear
+		}
+
+		// Akumulasi total waktu dan jarak berdasarkan kategori
+		switch category {
+		case "RunWalkHike":
+			stat.RunWalkHikeDistance += activity.Distance
+			stat.RunWalkHikeTime += activity.MovingTime
+		case "Bike":
+			stat.BikeDistance += activity.Distance
+			stat.BikeTime += activity.MovingTime
+		case "Other":
+			stat.OtherDistance += activity.Distance
+			stat.OtherTime += activity.MovingTime
+		case swimCategory:
+			stat.SwimDistance += activity.Distance
+			stat.SwimTime += activity.MovingTime
+		}
+
+		paceMap[monthYear] = stat
+	}
+
+	monthlyPaceStats := make([]MonthlyPaceStats, 0, len(paceMap))
+	for _, stat := range paceMap {
+		// Hitung Pace Rata-rata (detik per meter) untuk setiap kategori yang diaktifkan di PACE_CATEGORIES;
+		// kategori yang dinonaktifkan bernilai 0 (sama seperti bulan tanpa aktivitas)
+
+		// Run/Walk/Hike Pace
+		if stat.RunWalkHikeDistance > 0 && paceCategories["RunWalkHike"] {
+			stat.RunWalkHikePace = stat.RunWalkHikeTime / stat.RunWalkHikeDistance
+		}
+
+		// Bike Pace
+		if stat.BikeDistance > 0 && paceCategories["Bike"] {
+			stat.BikePace = stat.BikeTime / stat.BikeDistance
+		}
+
+		// Other Pace
+		if stat.OtherDistance > 0 && paceCategories["Other"] {
+			stat.OtherPace = stat.OtherTime / stat.OtherDistance
+		}
+
+		// Swim Pace (detik per 100 meter)
+		if stat.SwimDistance > 0 && paceCategories[swimCategory] {
+			stat.SwimPace = stat.SwimTime / stat.SwimDistance * 100
+		}
+
+		monthlyPaceStats = append(monthlyPaceStats, stat)
 	}
 
-	fmt.Printf("Token lama kedaluwarsa. Mencoba refresh token...\n")
+	sort.Slice(monthlyPaceStats, func(i, j int) bool {
+		return monthlyPaceStats[i].MonthYear < monthlyPaceStats[j].MonthYear
+	})
+
+	return monthlyPaceStats, nil
+}
 
-	data := url.Values{}
-	data.Set("client_id", clientID)
-	data.Set("client_secret", clientSecret)
-	data.Set("grant_type", "refresh_token")
-	data.Set("refresh_token", currentTokens.RefreshToken)
+// --------------------------------------
+// WEBHOOK FUNCTIONS
+// --------------------------------------
 
-	resp, err := http.PostForm("https://www.strava.com/oauth/token", data)
-	if err != nil {
-		return fmt.Errorf("gagal request refresh token: %w", err)
+// StravaWebhookEvent merepresentasikan event push dari Strava.
+type StravaWebhookEvent struct {
+	ObjectType     string                 `json:"object_type"` // "activity" atau "athlete"
+	ObjectID       int64                  `json:"object_id"`
+	AspectType     string                 `json:"aspect_type"` // "create", "update", atau "delete"
+	OwnerID        int64                  `json:"owner_id"`    // ID atlet
+	SubscriptionID int64                  `json:"subscription_id"`
+	EventTime      int64                  `json:"event_time"` // Unix timestamp
+	Updates        map[string]interface{} `json:"updates"`
+}
+
+// handleWebhookValidation menjawab challenge validasi subscription dari Strava.
+func (s *Server) handleWebhookValidation(c *gin.Context) {
+	if c.Query("hub.mode") != "subscribe" || s.cfg.WebhookVerifyToken == "" || c.Query("hub.verify_token") != s.cfg.WebhookVerifyToken {
+		c.JSON(http.StatusForbidden, gin.H{"error": msg(c, "webhook_verification_invalid")})
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("gagal refresh token. Status: %s, Body: %s", resp.Status, bodyBytes)
+	c.JSON(http.StatusOK, gin.H{"hub.challenge": c.Query("hub.challenge")})
+}
+
+// handleWebhookEvent menerima event dari Strava. Strava mengharapkan respons 200 dalam 2 detik,
+// sehingga event diproses di goroutine terpisah.
+func (s *Server) handleWebhookEvent(c *gin.Context) {
+	var event StravaWebhookEvent
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "webhook_event_invalid"), "details": err.Error()})
+		return
 	}
 
-	var newTokens StravaTokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&newTokens); err != nil {
-		return fmt.Errorf("gagal mengurai respons refresh token: %w", err)
+	// Endpoint ini publik; hanya event dari subscription yang didaftarkan aplikasi ini yang diproses
+	if subscriptionID := s.webhookSubscriptionID.Load(); subscriptionID == 0 || event.SubscriptionID != subscriptionID {
+		slog.Warn("Event webhook dengan subscription_id tidak dikenal ditolak", "subscription_id", event.SubscriptionID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg(c, "webhook_subscription_unknown")})
+		return
 	}
 
-	// Update token di memori dan file
-	currentTokens.AccessToken = newTokens.AccessToken
-	currentTokens.ExpiresAt = newTokens.ExpiresAt
-	if newTokens.RefreshToken != "" {
-		// Strava terkadang mengeluarkan refresh token baru, terkadang tidak.
-		currentTokens.RefreshToken = newTokens.RefreshToken
+	slog.Info("Event webhook diterima",
+		"object_type", event.ObjectType,
+		"object_id", event.ObjectID,
+		"aspect_type", event.AspectType,
+		"athlete_id", event.OwnerID)
+
+	// Request ID tetap terbawa ke log pemrosesan, tanpa ikut dibatalkan saat respons selesai
+	go s.processWebhookEvent(context.WithoutCancel(c.Request.Context()), event)
+
+	c.JSON(http.StatusOK, gin.H{"status": "received"})
+}
+
+// processWebhookEvent memperbarui cache lokal sesuai event aktivitas tanpa sinkronisasi penuh.
+// Event atlet yang mencabut akses diteruskan ke handleDeauthorization.
+func (s *Server) processWebhookEvent(ctx context.Context, event StravaWebhookEvent) {
+	if event.ObjectType == "athlete" && isDeauthorizationEvent(event) {
+		if err := handleDeauthorization(event.OwnerID); err != nil {
+			slog.ErrorContext(ctx, "Gagal menghapus data setelah deauthorization", "athlete_id", event.OwnerID, "error", err)
+		}
+		return
+	}
+	if event.ObjectType != "activity" {
+		return
 	}
 
-	// Simpan token baru
-	if err := saveToken(currentTokens); err != nil {
-		return fmt.Errorf("gagal menyimpan token yang di-refresh: %w", err)
+	// Aplikasi ini hanya melayani satu atlet; abaikan event milik atlet lain. Jika ID atlet belum
+	// diketahui (file token lama atau belum login), pemilik event tidak dapat dicocokkan sehingga diabaikan.
+	tokenMutex.Lock()
+	athleteID := currentTokens.AthleteID
+	tokenMutex.Unlock()
+	if athleteID == 0 || event.OwnerID != athleteID {
+		slog.WarnContext(ctx, "Event webhook untuk atlet yang tidak dikenal diabaikan", "athlete_id", event.OwnerID)
+		return
 	}
 
-	fmt.Println("Refresh token berhasil! Access token baru telah disimpan.")
-	return nil
+	var err error
+	switch event.AspectType {
+	case "create", "update":
+		err = s.syncSingleActivity(ctx, event.ObjectID)
+	case "delete":
+		err = removeCachedActivity(event.ObjectID)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Gagal memproses event webhook",
+			"object_id", event.ObjectID,
+			"aspect_type", event.AspectType,
+			"error", err)
+	}
 }
 
-// ensureValidToken memeriksa kedaluwarsa token dan melakukan refresh jika diperlukan.
-func ensureValidToken() (string, error) {
+// isDeauthorizationEvent memeriksa apakah event atlet menandakan akses aplikasi dicabut.
+// Strava mengirim aspect_type "update" dengan updates {"authorized": "false"}; "delete" juga diterima.
+func isDeauthorizationEvent(event StravaWebhookEvent) bool {
+	if event.AspectType == "delete" {
+		return true
+	}
+	authorized, _ := event.Updates["authorized"].(string)
+	return event.AspectType == "update" && authorized == "false"
+}
+
+// handleDeauthorization menghapus token serta semua cache data Strava (aktivitas, split, dan nama gear)
+// milik atlet yang mencabut akses aplikasi. Event untuk atlet lain diabaikan, begitu juga jika ID atlet
+// yang login belum diketahui (file token lama): data tidak dihapus tanpa kecocokan ID yang pasti.
+func handleDeauthorization(athleteID int64) error {
 	tokenMutex.Lock()
-	defer tokenMutex.Unlock()
+	currentAthleteID := currentTokens.AthleteID
+	tokenMutex.Unlock()
+	if currentAthleteID == 0 || athleteID != currentAthleteID {
+		slog.Warn("Deauthorization untuk atlet yang tidak dikenal diabaikan", "athlete_id", athleteID)
+		return nil
+	}
 
-	if currentTokens.AccessToken == "" {
-		return "", fmt.Errorf("access token tidak ada. Silakan login melalui /api/auth/strava")
+	if err := clearToken(); err != nil {
+		return err
 	}
 
-	// Cek apakah token akan kedaluwarsa dalam waktu dekat
-	expiryTime := time.Unix(currentTokens.ExpiresAt, 0)
-	if time.Now().Add(tokenTTLMargin).After(expiryTime) {
-		// Token sudah kedaluwarsa atau mendekati kedaluwarsa, lepaskan lock dan refresh.
-		// Catatan: refreshAccessToken akan mengakuisisi lock-nya sendiri.
-		tokenMutex.Unlock()
-		defer tokenMutex.Lock()
-		if err := refreshAccessToken(); err != nil {
-			return "", err
-		}
+	activitiesFileMutex.Lock()
+	err := dataFS.Remove(dataFilePath)
+	if err == nil || os.IsNotExist(err) {
+		err = dataFS.Remove(legacyDataFilePath)
+	}
+	if err == nil || os.IsNotExist(err) {
+		err = dataFS.Remove(cacheMetaFilePath)
+	}
+	invalidateActivityCache()
+	activitiesFileMutex.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("gagal menghapus cache aktivitas: %w", err)
+	}
+
+	if err := dataFS.RemoveAll(splitsDir); err != nil {
+		return fmt.Errorf("gagal menghapus cache split: %w", err)
+	}
+	if err := dataFS.RemoveAll(streamsDir); err != nil {
+		return fmt.Errorf("gagal menghapus cache stream: %w", err)
+	}
+
+	gearMutex.Lock()
+	err = dataFS.Remove(gearFilePath)
+	gearMutex.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("gagal menghapus cache nama gear: %w", err)
 	}
 
-	return currentTokens.AccessToken, nil
+	slog.Info("Akses Strava dicabut. Token dan cache data atlet dihapus.", "athlete_id", athleteID)
+	return nil
 }
 
-// --------------------------------------
-// HANDLER FUNCTIONS
-// --------------------------------------
+// syncSingleActivity mengambil satu aktivitas dari Strava dan menggabungkannya ke cache.
+func (s *Server) syncSingleActivity(ctx context.Context, activityID int64) error {
+	accessToken, err := s.ensureValidToken(ctx)
+	if err != nil {
+		return err
+	}
 
-// Tambahkan fungsi pembantu agar dapat memuat StravaActivity lengkap untuk summary
-func loadActivitiesInStravaFormat() []StravaActivity {
-	data, err := os.ReadFile("data/strava_activities.json")
+	activity, err := fetchSingleActivity(ctx, accessToken, activityID)
 	if err != nil {
-		log.Println("Error reading data file:", err)
-		return nil
+		return err
 	}
-	var activities []StravaActivity
-	if err := json.Unmarshal(data, &activities); err != nil {
-		log.Println("Error unmarshaling activities:", err)
-		return nil
+
+	if err := cacheActivity(activity); err != nil {
+		return err
 	}
-	return activities
+	slog.InfoContext(ctx, "Aktivitas dari webhook disimpan ke cache", "activity_id", activityID)
+
+	if err := syncVelocityStreams(ctx, accessToken); err != nil {
+		slog.WarnContext(ctx, "Sinkronisasi stream kecepatan gagal", "activity_id", activityID, "error", err)
+	}
+	return nil
 }
 
-// fetchActivitiesFromStrava mengambil data dari cache lokal (data/strava_activities.json)
-// dan memfilternya berdasarkan rentang tanggal yang diminta (inklusif).
-// Parameter:
-// - accessToken: Tidak digunakan karena membaca dari cache lokal.
-// - startDate, endDate: Rentang waktu (inklusif), harus berupa UTC 00:00:00.
-func fetchActivitiesFromStrava(accessToken string, startDate, endDate time.Time) ([]MinimalActivityData, error) {
-	// Abaikan accessToken karena kita menggunakan cache lokal untuk performa.
+// cacheActivity menggabungkan satu aktivitas ke cache lokal (menimpa entri dengan ID yang sama).
+func cacheActivity(activity map[string]interface{}) error {
+	activitiesFileMutex.Lock()
+	defer activitiesFileMutex.Unlock()
 
-	// 1. Baca semua aktivitas dari cache lokal
-	allActivities, err := readLocalActivities()
-	if err != nil {
-		// Langsung kembalikan error jika gagal membaca/mengurai file cache
-		return nil, fmt.Errorf("gagal membaca data aktivitas lokal: %w", err)
+	// Cache yang belum ada diperlakukan sebagai daftar kosong
+	var existing []map[string]interface{}
+	if _, _, statErr := statActivitiesFile(); statErr == nil {
+		var err error
+		existing, err = readRawActivities()
+		if err != nil {
+			return err
+		}
 	}
 
-	var filteredActivities []MinimalActivityData
+	return saveActivitiesFile(mergeActivities(existing, []map[string]interface{}{activity}))
+}
 
-	// Untuk mencakup seluruh hari terakhir (endDate), kita cari aktivitas
-	// yang dimulai SEBELUM awal hari berikutnya.
-	nextDayStart := endDate.AddDate(0, 0, 1) // Ini adalah 00:00:00Z di hari Senin minggu berikutnya
+// findActivityByID mencari aktivitas dengan ID tertentu. Mengembalikan nil jika tidak ada.
+func findActivityByID(activities []map[string]interface{}, activityID int64) map[string]interface{} {
+	for _, activity := range activities {
+		if id, ok := getFloat(activity["id"]); ok && int64(id) == activityID {
+			return activity
+		}
+	}
+	return nil
+}
 
-	for _, activity := range allActivities {
-		// Parse tanggal mulai aktivitas yang tersimpan dalam format RFC3339 (yang selalu UTC)
-		t, err := time.Parse(time.RFC3339, activity.StartDate)
-		if err != nil {
-			fmt.Printf("Peringatan: Gagal mengurai tanggal aktivitas '%s'. Aktivitas dilewati.\n", activity.StartDate)
+// removeCachedActivity menghapus aktivitas dengan ID tertentu dari cache lokal.
+func removeCachedActivity(activityID int64) error {
+	activitiesFileMutex.Lock()
+	defer activitiesFileMutex.Unlock()
+
+	existing, err := readRawActivities()
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]map[string]interface{}, 0, len(existing))
+	for _, activity := range existing {
+		if id, ok := getFloat(activity["id"]); ok && int64(id) == activityID {
 			continue
 		}
+		remaining = append(remaining, activity)
+	}
 
-		// Filter: activity time harus >= startDate (inklusi Senin 00:00:00Z)
-		// DAN activity time < nextDayStart (inklusi Minggu 23:59:59Z)
-		isAfterOrEqualStart := t.Equal(startDate) || t.After(startDate)
-		isBeforeNextDay := t.Before(nextDayStart)
+	if len(remaining) == len(existing) {
+		return nil // Aktivitas tidak ada di cache
+	}
 
-		if isAfterOrEqualStart && isBeforeNextDay {
-			filteredActivities = append(filteredActivities, activity)
-		}
+	if err := saveActivitiesFile(remaining); err != nil {
+		return err
 	}
 
-	return filteredActivities, nil
+	slog.Info("Aktivitas dihapus dari cache", "activity_id", activityID, "activity_count", len(remaining))
+	return nil
 }
 
-func handleStatus(c *gin.Context) {
-	// Cek status file data
-	_, err := os.Stat(dataFilePath)
-	fileStatus := "Not Found"
-	if err == nil {
-		fileStatus = "OK"
-	} else if os.IsNotExist(err) {
-		fileStatus = "Missing"
-	} else {
-		fileStatus = fmt.Sprintf("Error: %s", err.Error())
+// errActivityNotFound dikembalikan jika Strava merespons 404 untuk aktivitas yang diminta.
+var errActivityNotFound = errors.New("aktivitas tidak ditemukan di Strava")
+
+// fetchSingleActivity mengambil detail satu aktivitas dari Strava.
+func fetchSingleActivity(ctx context.Context, accessToken string, activityID int64) (map[string]interface{}, error) {
+	activityURL := fmt.Sprintf("https://www.strava.com/api/v3/activities/%d", activityID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, activityURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat request: %w", err)
 	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
 
-	tokenMutex.Lock()
-	isTokenValid := currentTokens.AccessToken != "" && time.Now().Before(time.Unix(currentTokens.ExpiresAt, 0).Add(-tokenTTLMargin))
-	expiryInfo := "N/A"
-	if currentTokens.ExpiresAt > 0 {
-		expiryInfo = time.Unix(currentTokens.ExpiresAt, 0).Format(time.RFC822)
+	resp, err := stravaClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gagal mengambil aktivitas %d dari Strava: %w", activityID, err)
 	}
-	tokenMutex.Unlock()
+	defer resp.Body.Close()
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":        "Backend is running 🟢",
-		"data_file":     dataFilePath,
-		"file_status":   fileStatus,
-		"token_status":  isTokenValid,
-		"token_expires": expiryInfo,
-		"refresh_token": currentTokens.RefreshToken != "", // Hanya untuk debug, cek apakah refresh token ada
-	})
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("aktivitas %d: %w", activityID, errActivityNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("API Strava error: %s - Body: %s", resp.Status, bodyBytes)
+	}
+
+	var activity map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&activity); err != nil {
+		return nil, fmt.Errorf("gagal mengurai respons aktivitas: %w", err)
+	}
+
+	return activity, nil
 }
 
-// handleStravaLogin mengarahkan pengguna ke halaman otorisasi Strava.
-func handleStravaLogin(c *gin.Context) {
-	authURL := fmt.Sprintf(
-		"http://www.strava.com/oauth/authorize?client_id=%s&response_type=code&redirect_uri=%s&scope=%s&approval_prompt=force", // approval_prompt=force agar dapat refresh token baru
-		clientID,
-		redirectURI,
-		scope,
-	)
-	c.Redirect(http.StatusFound, authURL)
+// activityStreams adalah stream Strava (key_by_type=true) yang dipakai untuk ekspor GPX dan pembagian zona pace.
+// Semua stream memiliki panjang yang sama; altitude bisa kosong untuk aktivitas tanpa data elevasi.
+type activityStreams struct {
+	LatLng struct {
+		Data [][2]float64 `json:"data"`
+	} `json:"latlng"`
+	Time struct {
+		Data []float64 `json:"data"` // Detik sejak start_date
+	} `json:"time"`
+	Altitude struct {
+		Data []float64 `json:"data"` // Meter
+	} `json:"altitude"`
+	Distance struct {
+		Data []float64 `json:"data"` // Meter kumulatif sejak awal aktivitas
+	} `json:"distance"`
+	VelocitySmooth struct {
+		Data []float64 `json:"data"` // m/s
+	} `json:"velocity_smooth"`
 }
 
-// handleStravaCallback menangani respons dari Strava dan menukar kode otorisasi dengan token.
-func handleStravaCallback(c *gin.Context) {
-	code := c.Query("code")
-	if code == "" {
-		if c.Query("error") != "" {
-			// Pengguna menolak otorisasi
-			c.Redirect(http.StatusTemporaryRedirect, frontendURL+"/?auth_status=denied")
-			return
-		}
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Authorization code not found"})
-		return
-	}
+// fetchActivityStreams mengambil stream latlng, time, altitude, distance, dan velocity_smooth satu aktivitas dari Strava.
+func fetchActivityStreams(ctx context.Context, accessToken string, activityID int64, keys string) (activityStreams, error) {
+	var streams activityStreams
+	streamsURL := fmt.Sprintf("https://www.strava.com/api/v3/activities/%d/streams?keys=%s&key_by_type=true", activityID, keys)
 
-	data := url.Values{}
-	data.Set("client_id", clientID)
-	data.Set("client_secret", clientSecret)
-	data.Set("code", code)
-	data.Set("grant_type", "authorization_code")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamsURL, nil)
+	if err != nil {
+		return streams, fmt.Errorf("gagal membuat request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
 
-	// Lakukan penukaran token
-	resp, err := http.PostForm("https://www.strava.com/oauth/token", data)
+	resp, err := stravaClient.Do(req)
 	if err != nil {
-		fmt.Printf("Error postForm Strava: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request token from Strava"})
-		return
+		return streams, fmt.Errorf("gagal mengambil stream aktivitas %d dari Strava: %w", activityID, err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return streams, fmt.Errorf("aktivitas %d: %w", activityID, errActivityNotFound)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return streams, parseRateLimitError(resp.Header, time.Now())
+	}
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		fmt.Printf("Strava token exchange failed. Status: %s, Body: %s\n", resp.Status, bodyBytes)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Strava token exchange failed", "status": resp.Status, "response": string(bodyBytes)})
-		return
+		return streams, fmt.Errorf("API Strava error: %s - Body: %s", resp.Status, bodyBytes)
 	}
 
-	var tokenResponse StravaTokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
-		fmt.Printf("Error decoding token response: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode token response"})
-		return
+	if err := json.NewDecoder(resp.Body).Decode(&streams); err != nil {
+		return streams, fmt.Errorf("gagal mengurai stream aktivitas: %w", err)
 	}
+	return streams, nil
+}
 
-	// --- FIX: Simpan SEMUA data token (termasuk refresh token) ke file lokal ---
-	if err := saveToken(TokenData(tokenResponse)); err != nil {
-		fmt.Printf("Error saving token: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save token locally"})
-		return
-	}
+// Struktur dokumen GPX 1.1 (https://www.topografix.com/GPX/1/1/)
+type gpxDocument struct {
+	XMLName  xml.Name    `xml:"gpx"`
+	Xmlns    string      `xml:"xmlns,attr"`
+	Version  string      `xml:"version,attr"`
+	Creator  string      `xml:"creator,attr"`
+	Metadata gpxMetadata `xml:"metadata"`
+	Track    gpxTrack    `xml:"trk"`
+}
 
-	// Alihkan ke frontend. Token kini dikelola di backend.
-	fmt.Println("Token berhasil didapatkan dan disimpan. Mengarahkan ke frontend.")
-	c.Redirect(http.StatusTemporaryRedirect, fmt.Sprintf("%s/?auth_status=success", frontendURL))
+type gpxMetadata struct {
+	Name string `xml:"name,omitempty"`
+	Time string `xml:"time,omitempty"`
 }
 
-// handleGetActivities: Logika Caching dan Refresh Token
-func handleGetActivities(c *gin.Context) {
-	// Pastikan token valid atau refresh token
-	accessToken, err := ensureValidToken()
-	if err != nil {
-		fmt.Printf("Error during token check/refresh: %v\n", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token tidak valid atau gagal di-refresh. Silakan login ulang via /api/auth/strava", "details": err.Error()})
-		return
-	}
+type gpxTrack struct {
+	Name    string     `xml:"name,omitempty"`
+	Type    string     `xml:"type,omitempty"`
+	Segment gpxSegment `xml:"trkseg"`
+}
 
-	shouldRefresh := c.Query("refresh") == "true"
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
 
-	// 1. Cek file lokal dan kondisi refresh
-	_, err = os.Stat(dataFilePath)
-	fileExist := err == nil
+type gpxPoint struct {
+	Lat       float64  `xml:"lat,attr"`
+	Lon       float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele,omitempty"`
+	Time      string   `xml:"time,omitempty"`
+}
 
-	if fileExist && !shouldRefresh {
-		// Logika membaca file lokal yang sama
-		fmt.Println("Membaca data dari file lokal:", dataFilePath)
-		fileContent, err := os.ReadFile(dataFilePath)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membaca file lokal", "details": err.Error()})
-			return
-		}
+// buildActivityGPX menyusun dokumen GPX 1.1 dari detail aktivitas (name, type, start_date) dan stream-nya.
+// Waktu tiap titik dihitung dari start_date ditambah stream time; dilewati jika start_date tidak valid.
+func buildActivityGPX(activity map[string]interface{}, streams activityStreams) ([]byte, error) {
+	name, _ := activity["name"].(string)
+	activityType, _ := activity["type"].(string)
+	startDate, _ := activity["start_date"].(string)
+	start, startErr := time.Parse(time.RFC3339, startDate)
+
+	doc := gpxDocument{
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Version: "1.1",
+		Creator: "Strava Progress Tracker",
+		Metadata: gpxMetadata{
+			Name: name,
+		},
+		Track: gpxTrack{Name: name, Type: activityType},
+	}
+	if startErr == nil {
+		doc.Metadata.Time = start.UTC().Format(time.RFC3339)
+	}
 
-		var localActivities []map[string]interface{}
-		if err := json.Unmarshal(fileContent, &localActivities); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengurai file JSON lokal", "details": err.Error()})
-			fmt.Println("File JSON lokal rusak. Mencoba mengambil data baru...")
-		} else {
-			c.JSON(http.StatusOK, localActivities)
-			return
+	points := make([]gpxPoint, len(streams.LatLng.Data))
+	for i, latlng := range streams.LatLng.Data {
+		points[i] = gpxPoint{Lat: latlng[0], Lon: latlng[1]}
+		if i < len(streams.Altitude.Data) {
+			elevation := streams.Altitude.Data[i]
+			points[i].Elevation = &elevation
+		}
+		if startErr == nil && i < len(streams.Time.Data) {
+			offset := time.Duration(streams.Time.Data[i] * float64(time.Second))
+			points[i].Time = start.Add(offset).UTC().Format(time.RFC3339)
 		}
 	}
+	doc.Track.Segment.Points = points
 
-	// 2. Ambil data baru jika file tidak ada/rusak ATAU refresh diminta
-	if shouldRefresh {
-		fmt.Println("Memaksa refresh. Mengambil semua data baru dari Strava...")
-	} else {
-		fmt.Println("File lokal tidak ditemukan atau rusak. Mengambil data dari Strava...")
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("gagal marshal GPX: %w", err)
 	}
+	return append([]byte(xml.Header), data...), nil
+}
 
-	// Gunakan accessToken yang sudah dipastikan valid/baru dari ensureValidToken
-	if err := fetchAndSaveAllActivities(accessToken); err != nil {
-		fmt.Printf("Error fetchAndSaveAllActivities: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil dan menyimpan aktivitas dari Strava", "details": err.Error()})
-		return
-	}
+// ActivitySplit adalah ringkasan satu split per kilometer dari splits_metric Strava.
+type ActivitySplit struct {
+	Split      int      `json:"split"`
+	Distance   float64  `json:"distance"`    // Meter
+	MovingTime float64  `json:"moving_time"` // Detik
+	Pace       *float64 `json:"pace"`        // Menit/km; null jika split tidak bergerak
+}
 
-	// 3. Baca ulang data yang baru disimpan dan kirimkan ke frontend
-	fileContent, err := os.ReadFile(dataFilePath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal membaca file setelah sinkronisasi.", "details": err.Error()})
-		return
+// extractMetricSplits membaca splits_metric dari detail aktivitas Strava.
+// Aktivitas tanpa split (mis. aktivitas manual) menghasilkan slice kosong.
+func extractMetricSplits(activity map[string]interface{}) []ActivitySplit {
+	rawSplits, _ := activity["splits_metric"].([]interface{})
+
+	splits := make([]ActivitySplit, 0, len(rawSplits))
+	for i, raw := range rawSplits {
+		entry, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		split := ActivitySplit{Split: i + 1}
+		if number, ok := getFloat(entry["split"]); ok {
+			split.Split = int(number)
+		}
+		split.Distance, _ = getFloat(entry["distance"])
+		split.MovingTime, _ = getFloat(entry["moving_time"])
+		if speed, ok := averageSpeed(split.Distance, split.MovingTime); ok {
+			pace := 1000.0 / speed / 60.0
+			split.Pace = &pace
+		}
+		splits = append(splits, split)
 	}
-	var savedActivities []map[string]interface{}
-	json.Unmarshal(fileContent, &savedActivities)
+	return splits
+}
 
-	c.JSON(http.StatusOK, savedActivities)
+// splitsFilePath mengembalikan lokasi cache split untuk satu aktivitas.
+func splitsFilePath(activityID int64) string {
+	return filepath.Join(splitsDir, fmt.Sprintf("%d.json", activityID))
 }
 
-// main.go (Tambahkan atau pastikan fungsi ini ada)
-func loadLocalActivities() []StravaActivity {
-	// Pastikan path ke file lokal sudah benar
-	data, err := os.ReadFile("data/strava_activities.json")
+// loadCachedSplits membaca split dari cache lokal. Error membungkus os.ErrNotExist jika belum ada cache.
+func loadCachedSplits(activityID int64) ([]ActivitySplit, error) {
+	data, err := dataFS.ReadFile(splitsFilePath(activityID))
 	if err != nil {
-		log.Println("Error reading data file:", err)
-		return nil
+		return nil, fmt.Errorf("gagal membaca cache split: %w", err)
 	}
 
-	var activities []StravaActivity // Menggunakan StravaActivity
-	if err := json.Unmarshal(data, &activities); err != nil {
-		log.Println("Error unmarshaling activities:", err)
-		return nil
+	var splits []ActivitySplit
+	if err := json.Unmarshal(data, &splits); err != nil {
+		return nil, fmt.Errorf("gagal mengurai cache split: %w", err)
 	}
-	return activities
+	return splits, nil
 }
 
-func calculatePaceStats(activity StravaActivity) PaceStat {
-	var stats PaceStat
-
-	// Hanya proses aktivitas lari/run
-	if activity.Type != "Run" {
-		return stats // Mengembalikan PaceStat kosong
+// saveCachedSplits menulis split satu aktivitas ke data/splits/<id>.json.
+func saveCachedSplits(activityID int64, splits []ActivitySplit) error {
+	if err := dataFS.MkdirAll(splitsDir, 0755); err != nil {
+		return fmt.Errorf("gagal membuat direktori split: %w", err)
 	}
 
-	// Jarak (meter)
-	distanceM := activity.Distance
-	// Waktu bergerak (detik)
-	movingTimeS := activity.MovingTime
+	data, err := json.MarshalIndent(splits, "", " ")
+	if err != nil {
+		return fmt.Errorf("gagal marshal split: %w", err)
+	}
 
-	if distanceM <= 0 || movingTimeS <= 0 {
-		return stats
+	if err := dataFS.WriteFile(splitsFilePath(activityID), data, 0644); err != nil {
+		return fmt.Errorf("gagal menulis cache split: %w", err)
 	}
+	return nil
+}
 
-	// Kecepatan rata-rata (meter/detik)
-	avgSpeedMPS := distanceM / movingTimeS
+// velocityStream adalah cache stream distance dan velocity_smooth satu aktivitas (data/streams/<id>.json).
+type velocityStream struct {
+	Distance []float64 `json:"distance"` // Meter kumulatif
+	Velocity []float64 `json:"velocity"` // m/s
+}
 
-	// Zona pace ilustratif (sesuai dengan frontend)
-	paceZone := getPaceZone(avgSpeedMPS)
+// streamsFilePath mengembalikan lokasi cache stream kecepatan untuk satu aktivitas.
+func streamsFilePath(activityID int64) string {
+	return filepath.Join(streamsDir, fmt.Sprintf("%d.json", activityID))
+}
 
-	// Konversi jarak total ke KM
-	distanceKM := distanceM / 1000.0
+// loadCachedVelocityStream membaca stream kecepatan dari cache lokal. Error membungkus os.ErrNotExist jika belum ada cache.
+func loadCachedVelocityStream(activityID int64) (velocityStream, error) {
+	var stream velocityStream
+	data, err := dataFS.ReadFile(streamsFilePath(activityID))
+	if err != nil {
+		return stream, fmt.Errorf("gagal membaca cache stream: %w", err)
+	}
+	if err := json.Unmarshal(data, &stream); err != nil {
+		return stream, fmt.Errorf("gagal mengurai cache stream: %w", err)
+	}
+	return stream, nil
+}
 
-	// Distribusikan Jarak total ke zona yang ditentukan
-	switch paceZone {
-	case "🔴 Merah (Maks/Interval)":
-		stats.Red = distanceKM
-	case "🟠 Oranye (Tempo/Threshold)":
-		stats.Orange = distanceKM
-	case "🟡 Kuning (Steady/Aerobic)":
-		stats.Yellow = distanceKM
-	case "🟢 Hijau (Easy/Recovery)":
-		stats.Green = distanceKM
+// saveCachedVelocityStream menulis stream distance dan velocity_smooth ke data/streams/<id>.json.
+// Aktivitas tanpa kedua stream tersebut (mis. latihan di dalam ruangan tanpa sensor) disimpan sebagai
+// stream kosong, sebagai penanda agar syncVelocityStreams tidak mengambilnya ulang; paceStatsForActivity
+// memakai kecepatan rata-rata untuk stream kosong.
+func saveCachedVelocityStream(activityID int64, streams activityStreams) error {
+	stream := velocityStream{Distance: streams.Distance.Data, Velocity: streams.VelocitySmooth.Data}
+	if len(stream.Distance) == 0 || len(stream.Velocity) == 0 {
+		stream = velocityStream{}
+	}
+	if err := dataFS.MkdirAll(streamsDir, 0755); err != nil {
+		return fmt.Errorf("gagal membuat direktori stream: %w", err)
 	}
 
-	return stats
+	data, err := json.Marshal(stream)
+	if err != nil {
+		return fmt.Errorf("gagal marshal stream: %w", err)
+	}
+
+	if err := dataFS.WriteFile(streamsFilePath(activityID), data, 0644); err != nil {
+		return fmt.Errorf("gagal menulis cache stream: %w", err)
+	}
+	return nil
 }
 
-// PaceStat digunakan untuk mengembalikan data agregasi statistik
-// CATATAN: Struktur ini tidak lagi digunakan, tetapi dipertahankan agar kode kompilasi
-// type PaceStat struct {
-// 	PaceDistances map[string]float64 `json:"paceDistances"`
-// }
+// Stream yang diminta dari Strava: lengkap untuk ekspor GPX, atau hanya yang dipakai pembagian zona pace.
+const (
+	gpxStreamKeys      = "latlng,time,altitude,distance,velocity_smooth"
+	velocityStreamKeys = "distance,velocity_smooth"
+)
 
-// getPaceZone mengelompokkan kecepatan rata-rata (m/s) ke dalam zona warna
-func getPaceZone(speed float64) string {
-	// Pace zones ilustratif berdasarkan kecepatan (m/s)
-	// Kecepatan dihitung dari distance/elapsed_time
-	// Semakin tinggi m/s, semakin cepat lari
-	if speed >= 4.8 {
-		return "🔴 Merah (Maks/Interval)" // Pace < 3:28 /km
-	} else if speed >= 3.8 {
-		return "🟠 Oranye (Tempo/Threshold)" // Pace 3:28 - 4:23 /km
-	} else if speed >= 3.0 {
-		return "🟡 Kuning (Steady/Aerobic)" // Pace 4:23 - 5:33 /km
-	} else {
-		return "🟢 Hijau (Easy/Recovery)" // Pace > 5:33 /km
+// streamSyncLimit adalah jumlah maksimal stream kecepatan yang diambil per sinkronisasi (STREAM_SYNC_LIMIT).
+// Setiap stream memakai satu request dari kuota rate limit Strava, sehingga bawaan 0 menonaktifkannya:
+// stream hanya tersimpan dari ekspor GPX.
+var streamSyncLimit int
+
+// syncVelocityStreams mengambil stream kecepatan untuk aktivitas RunWalkHike terbaru di cache yang belum
+// memiliki cache stream, paling banyak streamSyncLimit per panggilan; aktivitas lama terisi bertahap pada
+// sinkronisasi berikutnya. Dipanggil setelah sinkronisasi aktivitas berhasil (manual, otomatis, dan webhook).
+// Pengambilan berhenti pada error pertama (mis. 429) agar sisa kuota tidak dihabiskan; error dikembalikan
+// hanya untuk dicatat atau untuk menunda sinkronisasi otomatis, karena aktivitasnya sendiri sudah tersimpan.
+func syncVelocityStreams(ctx context.Context, accessToken string) error {
+	if streamSyncLimit <= 0 {
+		return nil
+	}
+	activities, err := getCachedActivities()
+	if err != nil {
+		return err
+	}
+
+	cached := listCachedStreamIDs()
+	var missing []StravaActivity
+	for _, activity := range activities {
+		if activity.ID != 0 && classifyActivity(activity.Type) == "RunWalkHike" && !cached[activity.ID] {
+			missing = append(missing, activity)
+		}
+	}
+	// start_date selalu RFC3339 UTC (sufiks Z), sehingga urutan string sama dengan urutan waktu
+	sort.Slice(missing, func(i, j int) bool { return missing[i].StartDate > missing[j].StartDate })
+
+	fetched := 0
+	for _, activity := range missing[:min(streamSyncLimit, len(missing))] {
+		streams, err := fetchActivityStreams(ctx, accessToken, activity.ID, velocityStreamKeys)
+		if errors.Is(err, errActivityNotFound) {
+			continue // Aktivitas dihapus di Strava; cache akan diperbarui oleh webhook atau sinkronisasi berikutnya
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "Pengambilan stream kecepatan dihentikan", "activity_id", activity.ID, "fetched", fetched, "error", err)
+			return err
+		}
+		if err := saveCachedVelocityStream(activity.ID, streams); err != nil {
+			return err
+		}
+		fetched++
 	}
+	if fetched > 0 {
+		slog.InfoContext(ctx, "Stream kecepatan disimpan", "fetched", fetched, "remaining", len(missing)-fetched)
+	}
+	return nil
 }
 
-// handleGetWeeklyPaceStats: Mengambil aktivitas dalam rentang tanggal dan mengagregasi jarak per zona tempo
-func handleGetWeeklyPaceStats(c *gin.Context) {
-	// Gunakan UTC (atau zona waktu yang konsisten)
-	loc := time.UTC
+// gearMutex menyerialkan read-modify-write pada cache nama gear.
+var gearMutex sync.Mutex
 
-	// 1. Ambil query params startDate dan endDate
-	startQuery := c.Query("startDate")
-	endQuery := c.Query("endDate")
+// resolveGearNames mengisi Name pada stats dari cache nama gear. Gear yang belum ada di cache
+// diambil dari Strava (/gear/{id}) lalu disimpan. Kegagalan hanya dicatat; nama dibiarkan kosong.
+func (s *Server) resolveGearNames(ctx context.Context, stats []GearStat) {
+	gearMutex.Lock()
+	defer gearMutex.Unlock()
 
-	var startDate, endDate time.Time
-	var err error
+	names, err := loadGearNames()
+	if err != nil {
+		slog.WarnContext(ctx, "Gagal membaca cache nama gear", "error", err)
+		names = make(map[string]string)
+	}
+
+	var accessToken string
+	updated := false
+	for i := range stats {
+		gearID := stats[i].GearID
+		if gearID == unassignedGearID {
+			continue
+		}
+		if name, ok := names[gearID]; ok {
+			stats[i].Name = name
+			continue
+		}
 
-	if startQuery != "" && endQuery != "" {
-		// Logika parsing tanggal tetap sama
-		startDate, err = time.ParseInLocation("2006-01-02", startQuery, loc)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid startDate format. Use YYYY-MM-DD."})
-			return
+		if accessToken == "" {
+			accessToken, err = s.ensureValidToken(ctx)
+			if err != nil {
+				slog.WarnContext(ctx, "Nama gear tidak dapat diambil tanpa token valid", "error", err)
+				break
+			}
 		}
-		endDate, err = time.ParseInLocation("2006-01-02", endQuery, loc)
+
+		name, err := fetchGearName(ctx, accessToken, gearID)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid endDate format. Use YYYY-MM-DD."})
-			return
+			slog.WarnContext(ctx, "Gagal mengambil nama gear dari Strava", "gear_id", gearID, "error", err)
+			continue
 		}
-	} else {
-		// Logika default minggu ini tetap sama
-		now := time.Now().In(loc)
+		names[gearID] = name
+		stats[i].Name = name
+		updated = true
+	}
 
-		offset := int(time.Monday - now.Weekday())
-		if offset > 0 {
-			offset = -6
+	if updated {
+		if err := saveGearNames(names); err != nil {
+			slog.WarnContext(ctx, "Gagal menyimpan cache nama gear", "error", err)
 		}
-
-		startDate = now.AddDate(0, 0, offset).Truncate(24 * time.Hour)
-		endDate = startDate.AddDate(0, 0, 6).Truncate(24 * time.Hour)
 	}
+}
 
-	// 2. Muat aktivitas
-	// Asumsi: loadLocalActivities() mengembalikan []StravaActivity
-	activities := loadLocalActivities()
+// fetchGearName mengambil nama satu gear dari Strava.
+func fetchGearName(ctx context.Context, accessToken, gearID string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://www.strava.com/api/v3/gear/"+url.PathEscape(gearID), nil)
+	if err != nil {
+		return "", fmt.Errorf("gagal membuat request: %w", err)
+	}
+	req.Header.Add("Authorization", "Bearer "+accessToken)
 
-	// >>> LANGKAH BARU: HITUNG RINGKASAN MINGGUAN (Summary)
-	summary := calculateWeeklySummaryStats(activities, startDate, endDate)
+	resp, err := stravaClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("gagal mengambil gear %s dari Strava: %w", gearID, err)
+	}
+	defer resp.Body.Close()
 
-	// 3. Inisialisasi map data harian (WeeklyPaceData)
-	weeklyData := make(WeeklyPaceData)
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("API Strava error: %s - Body: %s", resp.Status, bodyBytes)
+	}
 
-	// Inisialisasi SEMUA 7 HARI DALAM MINGGU KE NOL
-	current := startDate
-	for current.Before(endDate.AddDate(0, 0, 1)) {
-		dateStr := current.Format("2006-01-02")
-		weeklyData[dateStr] = PaceStat{}
-		current = current.AddDate(0, 0, 1)
+	var gear struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&gear); err != nil {
+		return "", fmt.Errorf("gagal mengurai respons gear: %w", err)
 	}
+	return gear.Name, nil
+}
 
-	// 4. Iterasi dan hitung aktivitas harian (PaceData)
-	for _, activity := range activities {
-		// Pastikan menggunakan StartDateLocal untuk penanggalan harian yang akurat
-		activityTime, err := time.Parse(time.RFC3339, activity.StartDateLocal)
-		if err != nil {
-			continue
+// loadGearNames membaca cache nama gear (gear_id -> nama). File yang belum ada menghasilkan map kosong.
+func loadGearNames() (map[string]string, error) {
+	data, err := dataFS.ReadFile(gearFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]string), nil
 		}
+		return nil, err
+	}
 
-		activityDate := activityTime.In(loc).Truncate(24 * time.Hour)
-
-		// Cek apakah aktivitas berada dalam rentang [startDate, endDate]
-		if (activityDate.Equal(startDate) || activityDate.After(startDate)) &&
-			(activityDate.Equal(endDate) || activityDate.Before(endDate.AddDate(0, 0, 1))) {
-
-			dateStr := activityDate.Format("2006-01-02")
-
-			paceStats := calculatePaceStats(activity)
+	names := make(map[string]string)
+	if err := json.Unmarshal(data, &names); err != nil {
+		return nil, fmt.Errorf("gagal mengurai cache nama gear: %w", err)
+	}
+	return names, nil
+}
 
-			currentDayStats := weeklyData[dateStr]
-			currentDayStats.Red += paceStats.Red
-			currentDayStats.Orange += paceStats.Orange
-			currentDayStats.Yellow += paceStats.Yellow
-			currentDayStats.Green += paceStats.Green
-			weeklyData[dateStr] = currentDayStats
-		}
+// saveGearNames menulis cache nama gear ke file lokal.
+func saveGearNames(names map[string]string) error {
+	if err := dataFS.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("gagal membuat direktori data: %w", err)
 	}
 
-	// >>> LANGKAH BARU: Kumpulkan data harian dan ringkasan ke dalam GlobalWeeklyData
-	finalResponse := GlobalWeeklyData{
-		PaceData: weeklyData,
-		Summary:  summary,
+	data, err := json.MarshalIndent(names, "", " ")
+	if err != nil {
+		return fmt.Errorf("gagal marshal nama gear: %w", err)
 	}
 
-	// 5. Kirim GlobalWeeklyData sebagai respons JSON
-	c.JSON(http.StatusOK, finalResponse)
+	if err := dataFS.WriteFile(gearFilePath, data, 0644); err != nil {
+		return fmt.Errorf("gagal menulis cache nama gear: %w", err)
+	}
+	return nil
 }
 
-// handleGetDistanceStats: Mengembalikan ringkasan statistik jarak bulanan (Sama)
-func handleGetDistanceStats(c *gin.Context) {
-	// Periksa token sebelum mencoba membaca data lokal (data lokal dihasilkan dari Strava)
-	if _, err := ensureValidToken(); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token tidak valid, tidak dapat memproses data lokal. Silakan sinkronisasi ulang.", "details": err.Error()})
-		return
+// registerWebhookSubscription mendaftarkan subscription webhook ke Strava jika belum ada
+// subscription dengan callback URL yang sama.
+func (s *Server) registerWebhookSubscription() error {
+	const subscriptionsURL = "https://www.strava.com/api/v3/push_subscriptions"
+
+	// 1. Cek subscription yang sudah ada (Strava hanya mengizinkan satu per aplikasi)
+	query := url.Values{}
+	query.Set("client_id", s.cfg.ClientID)
+	query.Set("client_secret", s.cfg.ClientSecret)
+
+	resp, err := stravaClient.Get(subscriptionsURL + "?" + query.Encode())
+	if err != nil {
+		return fmt.Errorf("gagal mengambil daftar subscription: %w", err)
+	}
+	var existing []struct {
+		ID          int64  `json:"id"`
+		CallbackURL string `json:"callback_url"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&existing)
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && decodeErr == nil {
+		for _, sub := range existing {
+			if sub.CallbackURL == s.cfg.WebhookCallbackURL {
+				slog.Info("Webhook Strava sudah terdaftar", "subscription_id", sub.ID, "callback_url", sub.CallbackURL)
+				return s.setWebhookSubscriptionID(sub.ID)
+			}
+		}
 	}
 
-	stats, err := calculateMonthlyDistanceStats()
+	// 2. Daftarkan subscription baru
+	form := url.Values{}
+	form.Set("client_id", s.cfg.ClientID)
+	form.Set("client_secret", s.cfg.ClientSecret)
+	form.Set("callback_url", s.cfg.WebhookCallbackURL)
+	form.Set("verify_token", s.cfg.WebhookVerifyToken)
+
+	resp, err = stravaClient.PostForm(subscriptionsURL, form)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung statistik jarak", "details": err.Error()})
-		return
+		return fmt.Errorf("gagal request pendaftaran subscription: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("pendaftaran subscription ditolak. Status: %s, Body: %s", resp.Status, bodyBytes)
 	}
 
-	c.JSON(http.StatusOK, stats)
+	var created struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(bodyBytes, &created); err != nil || created.ID == 0 {
+		return fmt.Errorf("respons pendaftaran subscription tidak berisi ID: %s", bodyBytes)
+	}
+	slog.Info("Webhook Strava berhasil didaftarkan", "subscription_id", created.ID, "callback_url", s.cfg.WebhookCallbackURL)
+	return s.setWebhookSubscriptionID(created.ID)
 }
 
-// handleGetPaceStats: Mengembalikan ringkasan statistik pace bulanan (Sama)
-func handleGetPaceStats(c *gin.Context) {
-	// Periksa token sebelum mencoba membaca data lokal
-	if _, err := ensureValidToken(); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token tidak valid, tidak dapat memproses data lokal. Silakan sinkronisasi ulang.", "details": err.Error()})
-		return
+// setWebhookSubscriptionID mulai menerima event dari subscription id dan menyimpannya ke file,
+// agar event tetap diterima setelah restart walaupun pendaftaran ulang gagal.
+func (s *Server) setWebhookSubscriptionID(id int64) error {
+	s.webhookSubscriptionID.Store(id)
+
+	if err := dataFS.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("gagal membuat direktori data: %w", err)
+	}
+	data, err := json.MarshalIndent(map[string]int64{"subscription_id": id}, "", " ")
+	if err != nil {
+		return fmt.Errorf("gagal marshal ID subscription webhook: %w", err)
+	}
+	if err := dataFS.WriteFile(webhookFilePath, data, 0644); err != nil {
+		return fmt.Errorf("gagal menyimpan ID subscription webhook: %w", err)
 	}
+	return nil
+}
 
-	stats, err := calculateMonthlyPaceStats()
+// loadWebhookSubscriptionID membaca ID subscription webhook yang disimpan setWebhookSubscriptionID.
+func loadWebhookSubscriptionID() (int64, error) {
+	data, err := dataFS.ReadFile(webhookFilePath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghitung statistik pace", "details": err.Error()})
-		return
+		return 0, err
+	}
+	var stored struct {
+		SubscriptionID int64 `json:"subscription_id"`
 	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return 0, fmt.Errorf("gagal mengurai ID subscription webhook: %w", err)
+	}
+	return stored.SubscriptionID, nil
+}
 
-	c.JSON(http.StatusOK, stats)
+// randomToken membuat token acak (hex) untuk keperluan verifikasi.
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 // --------------------------------------
-// LOGIC FUNCTIONS
+// GOAL FUNCTIONS
 // --------------------------------------
 
-// fetchAndSaveAllActivities mengambil semua aktivitas dari Strava dan menyimpannya ke file JSON.
-// Menggunakan access token yang sudah dipastikan valid.
-func fetchAndSaveAllActivities(accessToken string) error {
-	var allActivities []map[string]interface{}
-	page := 1
-	perPage := 200 // Maksimal per_page untuk efisiensi
+// Goal: Target jarak bulanan untuk satu kategori aktivitas.
+// Kombinasi (Category, Month) bersifat unik; goal baru dengan kunci yang sama menimpa goal lama.
+type Goal struct {
+	Category     string  `json:"category"`      // RunWalkHike, Bike, atau Other (lihat classifyActivity)
+	Month        string  `json:"month"`         // Format: YYYY-MM
+	TargetMeters float64 `json:"target_meters"` // meter
+}
 
-	for {
-		activitiesURL := fmt.Sprintf(
-			"https://www.strava.com/api/v3/athlete/activities?per_page=%d&page=%d",
-			perPage,
-			page,
-		)
-
-		client := &http.Client{Timeout: 60 * time.Second} // Tambahkan timeout yang lebih lama
-		req, err := http.NewRequest("GET", activitiesURL, nil)
-		if err != nil {
-			return fmt.Errorf("gagal membuat request: %w", err)
-		}
-		// Gunakan access token yang valid
-		req.Header.Add("Authorization", "Bearer "+accessToken)
+// GoalProgress: Perbandingan goal dengan jarak aktual pada bulan tersebut
+type GoalProgress struct {
+	Goal
+	ActualMeters    float64 `json:"actual_meters"`
+	RemainingMeters float64 `json:"remaining_meters"` // 0 jika target sudah tercapai
+	PercentComplete float64 `json:"percent_complete"` // Dapat melebihi 100
+}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return fmt.Errorf("gagal mengambil aktivitas dari Strava (Timeout/Network Error): %w", err)
-		}
-		defer resp.Body.Close()
+// GoalRing: Progres satu goal untuk ring persentase, beserta status terhadap laju target
+type GoalRing struct {
+	Category string  `json:"category"`
+	Target   float64 `json:"target"`  // meter
+	Actual   float64 `json:"actual"`  // meter
+	Percent  float64 `json:"percent"` // Dapat melebihi 100
+	Status   string  `json:"status"`  // behind, on_track, atau complete
+}
 
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("API Strava error: %s - Body: %s", resp.Status, bodyBytes)
-		}
+// Status pada GoalRing
+const (
+	goalStatusBehind   = "behind"
+	goalStatusOnTrack  = "on_track"
+	goalStatusComplete = "complete"
+)
 
-		var currentActivities []map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&currentActivities); err != nil {
-			return fmt.Errorf("gagal mengurai respons Strava: %w", err)
-		}
+// goalsMutex menyerialkan read-modify-write pada file goals.
+var goalsMutex sync.Mutex
 
-		allActivities = append(allActivities, currentActivities...)
+// handleSetGoal: Menyimpan goal bulanan; goal dengan kategori dan bulan yang sama diperbarui (upsert)
+func (s *Server) handleSetGoal(c *gin.Context) {
+	var goal Goal
+	if err := c.ShouldBindJSON(&goal); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "goal_invalid"), "details": err.Error()})
+		return
+	}
+	if !isActivityCategory(goal.Category) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "goal_category_invalid")})
+		return
+	}
+	if _, err := time.Parse("2006-01", goal.Month); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_month")})
+		return
+	}
+	if goal.TargetMeters <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "goal_target_invalid")})
+		return
+	}
 
-		// Log kemajuan
-		fmt.Printf("Fetched page %d, activities count: %d\n", page, len(currentActivities))
+	goalsMutex.Lock()
+	defer goalsMutex.Unlock()
 
-		// Cek kondisi berhenti: jika kurang dari perPage, berarti ini adalah halaman terakhir
-		if len(currentActivities) < perPage {
-			break
-		}
-		page++
+	goals, err := loadGoals()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "goals_read_failed"), "details": err.Error()})
+		return
+	}
+	if err := saveGoals(upsertGoal(goals, goal)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "goal_save_failed"), "details": err.Error()})
+		return
 	}
 
-	// Buat folder data jika belum ada
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		return fmt.Errorf("gagal membuat direktori data: %w", err)
+	c.JSON(http.StatusOK, goal)
+}
+
+// handleGetGoalProgress: Mengembalikan progres setiap goal pada bulan ?month=YYYY-MM
+func (s *Server) handleGetGoalProgress(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	month := c.Query("month")
+	if _, err := time.Parse("2006-01", month); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_month")})
+		return
 	}
 
-	// Tulis semua aktivitas ke file JSON
-	file, err := os.Create(dataFilePath)
+	goalsMutex.Lock()
+	goals, err := loadGoals()
+	goalsMutex.Unlock()
 	if err != nil {
-		return fmt.Errorf("gagal membuat file data: %w", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "goals_read_failed"), "details": err.Error()})
+		return
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", " ") // Agar file JSON mudah dibaca
-	if err := encoder.Encode(allActivities); err != nil {
-		return fmt.Errorf("gagal menulis ke file JSON: %w", err)
+	progress, err := calculateGoalProgress(filter, goals, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "goal_progress_failed"), "details": err.Error()})
+		return
 	}
 
-	fmt.Printf("Sinkronisasi selesai. Total %d aktivitas disimpan ke %s\n", len(allActivities), dataFilePath)
-	return nil
+	c.JSON(http.StatusOK, progress)
 }
 
-// classifyActivity (Sama)
-func classifyActivity(activityType string) string {
-	switch activityType {
-	case "Run", "Walk", "Hike", "TrailRun":
-		return "RunWalkHike"
-	case "Ride", "VirtualRide", "Handcycle":
-		return "Bike"
-	default:
-		// Mencakup Swim, Yoga, AlpineSki, dll.
-		return "Other"
+// handleGetGoalRings: Mengembalikan ring progres setiap goal pada bulan ?month=YYYY-MM (bawaan bulan ini),
+// dengan status yang membandingkan jarak aktual terhadap bagian bulan yang sudah berlalu
+func (s *Server) handleGetGoalRings(c *gin.Context) {
+	filter, ok := parseStatsFilter(c)
+	if !ok {
+		return
+	}
+
+	now := s.clock.Now().In(appLocation)
+	month := c.DefaultQuery("month", now.Format("2006-01"))
+	if _, err := time.Parse("2006-01", month); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg(c, "invalid_month")})
+		return
 	}
-}
 
-// readLocalActivities (Sama)
-func readLocalActivities() ([]MinimalActivityData, error) {
-	fileContent, err := os.ReadFile(dataFilePath)
+	goalsMutex.Lock()
+	goals, err := loadGoals()
+	goalsMutex.Unlock()
 	if err != nil {
-		// Periksa apakah error karena file tidak ditemukan.
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("file data lokal '%s' tidak ditemukan. Silakan sinkronisasi data dari Strava terlebih dahulu", dataFilePath)
-		}
-		return nil, fmt.Errorf("gagal membaca file data lokal: %w", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "goals_read_failed"), "details": err.Error()})
+		return
 	}
 
-	var rawActivities []map[string]interface{}
-	if err := json.Unmarshal(fileContent, &rawActivities); err != nil {
-		return nil, fmt.Errorf("gagal mengurai file JSON: %w", err)
+	progress, err := calculateGoalProgress(filter, goals, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg(c, "goal_progress_failed"), "details": err.Error()})
+		return
 	}
 
-	var minimalActivities []MinimalActivityData
-	for _, activity := range rawActivities {
-		// Menggunakan type assertion yang lebih aman untuk menangani int/float
-		distance, _ := getFloat(activity["distance"])
-		movingTime, _ := getFloat(activity["moving_time"])
-		startDate, ok1 := activity["start_date"].(string)
-		activityType, ok2 := activity["type"].(string)
+	c.JSON(http.StatusOK, calculateGoalRings(progress, month, now))
+}
 
-		if ok1 && ok2 && distance > 0 && movingTime > 0 {
-			minimalActivities = append(minimalActivities, MinimalActivityData{
-				StartDate:  startDate,
-				Distance:   distance,
-				MovingTime: movingTime,
-				Type:       activityType,
-			})
+// calculateGoalRings mengubah progres goal menjadi ring. Status complete jika target tercapai; selain itu
+// on_track jika jarak aktual setidaknya target dikali bagian bulan yang sudah berlalu per now, dan behind
+// jika kurang. Bulan yang sudah lewat dianggap berlalu penuh, bulan mendatang belum berlalu sama sekali.
+func calculateGoalRings(progress []GoalProgress, month string, now time.Time) []GoalRing {
+	elapsed := monthElapsedFraction(month, now)
+
+	rings := make([]GoalRing, 0, len(progress))
+	for _, p := range progress {
+		status := goalStatusBehind
+		switch {
+		case p.ActualMeters >= p.TargetMeters:
+			status = goalStatusComplete
+		case p.ActualMeters >= p.TargetMeters*elapsed:
+			status = goalStatusOnTrack
 		}
-	}
 
-	if len(minimalActivities) == 0 {
-		return nil, fmt.Errorf("tidak ada aktivitas valid yang ditemukan dalam file lokal")
+		rings = append(rings, GoalRing{
+			Category: p.Category,
+			Target:   p.TargetMeters,
+			Actual:   p.ActualMeters,
+			Percent:  p.PercentComplete,
+			Status:   status,
+		})
 	}
-
-	return minimalActivities, nil
+	return rings
 }
 
-// getFloat (Sama)
-func getFloat(v interface{}) (float64, bool) {
-	switch f := v.(type) {
-	case float64:
-		return f, true
-	case int:
-		return float64(f), true
-	case int64:
-		return float64(f), true
-	default:
-		return 0, false
+// monthElapsedFraction mengembalikan bagian bulan month (YYYY-MM) yang sudah berlalu per now (0-1).
+// Bulan dihitung menurut jam dinding now, sama dengan pengelompokan start_date_local pada statistik bulanan.
+func monthElapsedFraction(month string, now time.Time) float64 {
+	start, err := time.ParseInLocation("2006-01", month, now.Location())
+	if err != nil {
+		return 0
 	}
+	end := start.AddDate(0, 1, 0)
+
+	switch {
+	case !now.After(start):
+		return 0
+	case !now.Before(end):
+		return 1
+	}
+	return now.Sub(start).Seconds() / end.Sub(start).Seconds()
 }
 
-// calculateMonthlyDistanceStats (Sama)
-func calculateMonthlyDistanceStats() ([]MonthlySportStats, error) {
-	activities, err := readLocalActivities()
+// calculateGoalProgress membandingkan goal pada bulan month dengan jarak aktual dari calculateMonthlyDistanceStats.
+// Bulan tanpa aktivitas dihitung sebagai jarak 0.
+func calculateGoalProgress(filter statsFilter, goals []Goal, month string) ([]GoalProgress, error) {
+	var actual MonthlySportStats
+	stats, err := calculateMonthlyDistanceStats(filter, month)
 	if err != nil {
 		return nil, err
 	}
+	if len(stats) > 0 {
+		actual = stats[0]
+	}
 
-	statsMap := make(map[string]MonthlySportStats)
-
-	for _, activity := range activities {
-		// Parse tanggal
-		t, err := time.Parse(time.RFC3339, activity.StartDate)
-		if err != nil {
-			continue // Lewati jika gagal parse tanggal
-		}
-		monthYear := t.Format("2006-01") // Format YYYY-MM
-
-		// Klasifikasi
-		category := classifyActivity(activity.Type)
-
-		stat, exists := statsMap[monthYear]
-		if !exists {
-			stat.MonthYear = monthYear
+	progress := []GoalProgress{}
+	for _, goal := range goals {
+		if goal.Month != month {
+			continue
 		}
 
-		// Tambahkan jarak (distance) ke kategori yang sesuai
-		switch category {
+		var actualMeters float64
+		switch goal.Category {
 		case "RunWalkHike":
-			stat.RunWalkHike += activity.Distance
+			actualMeters = actual.RunWalkHike
 		case "Bike":
-			stat.Bike += activity.Distance
+			actualMeters = actual.Bike
 		case "Other":
-			stat.Other += activity.Distance
+			actualMeters = actual.Other
 		}
 
-		statsMap[monthYear] = stat
+		progress = append(progress, GoalProgress{
+			Goal:            goal,
+			ActualMeters:    actualMeters,
+			RemainingMeters: max(goal.TargetMeters-actualMeters, 0),
+			PercentComplete: actualMeters / goal.TargetMeters * 100,
+		})
 	}
+	return progress, nil
+}
 
-	// Konversi map menjadi slice
-	var monthlyStats []MonthlySportStats
-	for _, stat := range statsMap {
-		monthlyStats = append(monthlyStats, stat)
+// upsertGoal mengganti goal dengan kategori dan bulan yang sama, atau menambahkannya jika belum ada.
+func upsertGoal(goals []Goal, goal Goal) []Goal {
+	for i := range goals {
+		if goals[i].Category == goal.Category && goals[i].Month == goal.Month {
+			goals[i] = goal
+			return goals
+		}
 	}
-
-	return monthlyStats, nil
+	return append(goals, goal)
 }
 
-// calculateMonthlyPaceStats (Sama)
-func calculateMonthlyPaceStats() ([]MonthlyPaceStats, error) {
-	activities, err := readLocalActivities()
+// loadGoals membaca semua goal dari file lokal. File yang belum ada berarti belum ada goal.
+func loadGoals() ([]Goal, error) {
+	data, err := dataFS.ReadFile(goalsFilePath)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return []Goal{}, nil
+		}
 		return nil, err
 	}
 
-	paceMap := make(map[string]MonthlyPaceStats)
+	var goals []Goal
+	if err := json.Unmarshal(data, &goals); err != nil {
+		return nil, fmt.Errorf("gagal mengurai file goals: %w", err)
+	}
+	return goals, nil
+}
 
-	for _, activity := range activities {
-		t, err := time.Parse(time.RFC3339, activity.StartDate)
-		if err != nil {
-			continue
-		}
-		monthYear := t.Format("2006-01")
+// saveGoals menulis semua goal ke file lokal.
+func saveGoals(goals []Goal) error {
+	if err := dataFS.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("gagal membuat direktori data: %w", err)
+	}
 
-		// Klasifikasi
-		category := classifyActivity(activity.Type)
+	data, err := json.MarshalIndent(goals, "", " ")
+	if err != nil {
+		return fmt.Errorf("gagal marshal goals: %w", err)
+	}
 
-		stat, exists := paceMap[monthYear]
-		if !exists {
-			stat.MonthYear = monthYear
-		}
+	if err := dataFS.WriteFile(goalsFilePath, data, 0644); err != nil {
+		return fmt.Errorf("gagal menulis file goals: %w", err)
+	}
+	return nil
+}
 
-		// Akumulasi total waktu dan jarak berdasarkan kategori
-		switch category {
-		case "RunWalkHike":
-			stat.RunWalkHikeDistance += activity.Distance
-			stat.RunWalkHikeTime += activity.MovingTime
-		case "Bike":
-			stat.BikeDistance += activity.Distance
-			stat.BikeTime += activity.MovingTime
-		case "Other":
-			stat.OtherDistance += activity.Distance
-			stat.OtherTime += activity.MovingTime
-		}
+// --------------------------------------
+// I18N FUNCTIONS
+// --------------------------------------
 
-		paceMap[monthYear] = stat
-	}
+// defaultLanguage dipakai jika Accept-Language kosong atau tidak ada bahasa yang didukung.
+const defaultLanguage = "id"
+
+// messageCatalog berisi pesan error API per bahasa, dengan kunci yang sama di setiap bahasa.
+var messageCatalog = map[string]map[string]string{
+	"id": {
+		"auth_code_missing":            "Kode otorisasi tidak ditemukan",
+		"token_request_failed":         "Gagal meminta token dari Strava",
+		"token_exchange_failed":        "Penukaran token Strava gagal",
+		"token_decode_failed":          "Gagal mengurai respons token",
+		"token_save_failed":            "Gagal menyimpan token secara lokal",
+		"token_invalid_relogin":        "Token tidak valid atau gagal di-refresh. Silakan login ulang via /api/auth/strava",
+		"invalid_mode":                 "Mode tidak valid. Gunakan 'incremental' atau kosongkan parameter.",
+		"sync_window_requires_refresh": "Parameter after/before hanya dapat dipakai bersama refresh=true.",
+		"invalid_sync_after":           "Format after tidak valid. Gunakan YYYY-MM-DD.",
+		"invalid_sync_before":          "Format before tidak valid. Gunakan YYYY-MM-DD.",
+		"invalid_sync_window":          "Tanggal after harus sebelum atau sama dengan before.",
+		"local_file_read_failed":       "Gagal membaca file lokal",
+		"local_file_parse_failed":      "Gagal mengurai file JSON lokal",
+		"cache_repair_failed":          "Gagal memulihkan file cache yang rusak. Cache lama disimpan sebagai .bak; coba sinkronisasi ulang.",
+		"rate_limited":                 "Batas rate API Strava terlampaui. Coba lagi setelah waktu reset.",
+		"sync_failed":                  "Gagal mengambil dan menyimpan aktivitas dari Strava",
+		"read_after_sync_failed":       "Gagal membaca file setelah sinkronisasi.",
+		"invalid_page":                 "Page tidak valid. Gunakan bilangan bulat positif.",
+		"invalid_per_page":             "per_page tidak valid. Gunakan bilangan bulat positif.",
+		"invalid_limit":                "limit tidak valid. Gunakan bilangan bulat positif.",
+		"invalid_weeks":                "weeks tidak valid. Gunakan bilangan bulat 1-52.",
+		"date_range_incomplete":        "startDate dan endDate harus diberikan bersamaan. Gunakan YYYY-MM-DD.",
+		"invalid_start_date":           "Format startDate tidak valid. Gunakan YYYY-MM-DD.",
+		"invalid_end_date":             "Format endDate tidak valid. Gunakan YYYY-MM-DD.",
+		"end_before_start":             "endDate tidak boleh sebelum startDate.",
+		"distance_stats_failed":        "Gagal menghitung statistik jarak",
+		"classification_invalid":       "File classification.json tidak valid. Override lama tetap dipakai.",
+		"pace_stats_failed":            "Gagal menghitung statistik pace",
+		"invalid_stats_category":       "Kategori tidak valid. Gunakan run_walk_hike, bike, atau other.",
+		"invalid_pace_trend_type":      "Tipe aktivitas harus termasuk lari/jalan/hiking (mis. Run, Walk, Hike, TrailRun).",
+		"invalid_pace_zone":            "Zona pace tidak valid. Gunakan 'red', 'orange', 'yellow', atau 'green'.",
+		"yearly_stats_failed":          "Gagal menghitung statistik jarak tahunan",
+		"summary_failed":               "Gagal menghitung ringkasan",
+		"period_conflict":              "Gunakan year atau month, bukan keduanya.",
+		"invalid_year":                 "Format year tidak valid. Gunakan YYYY.",
+		"invalid_calendar_metric":      "Nilai metric tidak valid. Gunakan distance atau count.",
+		"invalid_month":                "Format month tidak valid. Gunakan YYYY-MM.",
+		"invalid_units":                "Units tidak valid. Gunakan 'metric' atau 'imperial'.",
+		"invalid_expand_other":         "Nilai expand_other tidak valid. Gunakan 'true' atau 'false'.",
+		"webhook_verification_invalid": "Permintaan verifikasi webhook tidak valid",
+		"webhook_event_invalid":        "Event webhook tidak valid",
+		"webhook_subscription_unknown": "Event webhook bukan dari subscription aplikasi ini",
+		"goal_invalid":                 "Goal tidak valid",
+		"goal_category_invalid":        "Kategori tidak valid. Gunakan 'RunWalkHike', 'Bike', atau 'Other'.",
+		"goal_target_invalid":          "target_meters harus berupa angka positif.",
+		"goals_read_failed":            "Gagal membaca file goals",
+		"goal_save_failed":             "Gagal menyimpan goal",
+		"goal_progress_failed":         "Gagal menghitung progres goal",
+		"invalid_activity_id":          "ID aktivitas tidak valid. Gunakan bilangan bulat positif.",
+		"activity_not_found":           "Aktivitas tidak ditemukan",
+		"activity_fetch_failed":        "Gagal mengambil aktivitas dari Strava",
+		"activity_no_gps":              "Aktivitas ini tidak memiliki data GPS sehingga tidak dapat diekspor ke GPX",
+		"gpx_build_failed":             "Gagal membuat file GPX",
+		"social_stats_failed":          "Gagal menghitung statistik sosial",
+		"logout_failed":                "Gagal menghapus token",
+		"no_refresh_token":             "Tidak ada refresh token yang tersimpan. Silakan login melalui /api/auth/strava",
+		"token_refresh_failed":         "Gagal me-refresh token di Strava",
+		"cache_not_found":              "Cache aktivitas belum ada. Silakan sinkronisasi data dari Strava terlebih dahulu.",
+		"route_not_found":              "route tidak ditemukan",
+		"method_not_allowed":           "metode tidak diizinkan",
+		"invalid_include_private":      "include_private tidak valid. Gunakan 'true' atau 'false'.",
+		"invalid_include_commute":      "include_commute tidak valid. Gunakan 'true' atau 'false'.",
+		"oauth_state_failed":           "Gagal memulai otorisasi Strava",
+		"invalid_approval_prompt":      "Nilai prompt tidak valid. Gunakan auto atau force.",
+	},
+	"en": {
+		"auth_code_missing":            "Authorization code not found",
+		"token_request_failed":         "Failed to request token from Strava",
+		"token_exchange_failed":        "Strava token exchange failed",
+		"token_decode_failed":          "Failed to decode token response",
+		"token_save_failed":            "Failed to save token locally",
+		"token_invalid_relogin":        "Token is invalid or could not be refreshed. Please log in again via /api/auth/strava",
+		"invalid_mode":                 "Invalid mode. Use 'incremental' or omit the parameter.",
+		"sync_window_requires_refresh": "The after/before parameters can only be used with refresh=true.",
+		"invalid_sync_after":           "Invalid after format. Use YYYY-MM-DD.",
+		"invalid_sync_before":          "Invalid before format. Use YYYY-MM-DD.",
+		"invalid_sync_window":          "The after date must be on or before the before date.",
+		"local_file_read_failed":       "Failed to read local file",
+		"local_file_parse_failed":      "Failed to parse local JSON file",
+		"cache_repair_failed":          "Failed to repair the corrupt cache file. The old cache was kept as .bak; try syncing again.",
+		"rate_limited":                 "Strava API rate limit exceeded. Try again after the reset time.",
+		"sync_failed":                  "Failed to fetch and save activities from Strava",
+		"read_after_sync_failed":       "Failed to read file after sync.",
+		"invalid_page":                 "Invalid page. Use a positive integer.",
+		"invalid_per_page":             "Invalid per_page. Use a positive integer.",
+		"invalid_limit":                "Invalid limit. Use a positive integer.",
+		"invalid_weeks":                "Invalid weeks. Use an integer from 1 to 52.",
+		"date_range_incomplete":        "startDate and endDate must be provided together. Use YYYY-MM-DD.",
+		"invalid_start_date":           "Invalid startDate format. Use YYYY-MM-DD.",
+		"invalid_end_date":             "Invalid endDate format. Use YYYY-MM-DD.",
+		"end_before_start":             "endDate must not be before startDate.",
+		"distance_stats_failed":        "Failed to calculate distance stats",
+		"classification_invalid":       "classification.json is invalid. The previous overrides are still in use.",
+		"pace_stats_failed":            "Failed to calculate pace stats",
+		"invalid_stats_category":       "Invalid category. Use run_walk_hike, bike, or other.",
+		"invalid_pace_trend_type":      "Activity type must be a run/walk/hike type (e.g. Run, Walk, Hike, TrailRun).",
+		"invalid_pace_zone":            "Invalid pace zone. Use 'red', 'orange', 'yellow', or 'green'.",
+		"yearly_stats_failed":          "Failed to calculate yearly distance stats",
+		"summary_failed":               "Failed to calculate summary",
+		"period_conflict":              "Use either year or month, not both.",
+		"invalid_year":                 "Invalid year format. Use YYYY.",
+		"invalid_calendar_metric":      "Invalid metric value. Use distance or count.",
+		"invalid_month":                "Invalid month format. Use YYYY-MM.",
+		"invalid_units":                "Invalid units. Use 'metric' or 'imperial'.",
+		"invalid_expand_other":         "Invalid expand_other value. Use 'true' or 'false'.",
+		"webhook_verification_invalid": "Invalid webhook verification request",
+		"webhook_event_invalid":        "Invalid webhook event",
+		"webhook_subscription_unknown": "Webhook event is not from this app's subscription",
+		"goal_invalid":                 "Invalid goal",
+		"goal_category_invalid":        "Invalid category. Use 'RunWalkHike', 'Bike', or 'Other'.",
+		"goal_target_invalid":          "target_meters must be a positive number.",
+		"goals_read_failed":            "Failed to read goals file",
+		"goal_save_failed":             "Failed to save goal",
+		"goal_progress_failed":         "Failed to calculate goal progress",
+		"invalid_activity_id":          "Invalid activity ID. Use a positive integer.",
+		"activity_not_found":           "Activity not found",
+		"activity_fetch_failed":        "Failed to fetch activity from Strava",
+		"activity_no_gps":              "This activity has no GPS data and cannot be exported to GPX",
+		"gpx_build_failed":             "Failed to build GPX file",
+		"social_stats_failed":          "Failed to calculate social stats",
+		"logout_failed":                "Failed to clear stored tokens",
+		"no_refresh_token":             "No refresh token is stored. Please log in via /api/auth/strava",
+		"token_refresh_failed":         "Failed to refresh the token with Strava",
+		"cache_not_found":              "Activity cache does not exist yet. Please sync data from Strava first.",
+		"route_not_found":              "route not found",
+		"method_not_allowed":           "method not allowed",
+		"invalid_include_private":      "Invalid include_private. Use 'true' or 'false'.",
+		"invalid_include_commute":      "Invalid include_commute. Use 'true' or 'false'.",
+		"oauth_state_failed":           "Failed to start Strava authorization",
+		"invalid_approval_prompt":      "Invalid prompt value. Use auto or force.",
+	},
+}
 
-	var monthlyPaceStats []MonthlyPaceStats
-	for _, stat := range paceMap {
-		// Hitung Pace Rata-rata (detik per meter) untuk setiap kategori
+// msg mengembalikan pesan untuk key dalam bahasa dari header Accept-Language.
+// Jika key tidak ada pada bahasa tersebut, dipakai bahasa bawaan, lalu key itu sendiri.
+func msg(c *gin.Context, key string) string {
+	if text, ok := messageCatalog[requestLanguage(c)][key]; ok {
+		return text
+	}
+	if text, ok := messageCatalog[defaultLanguage][key]; ok {
+		return text
+	}
+	return key
+}
 
-		// Run/Walk/Hike Pace
-		if stat.RunWalkHikeDistance > 0 {
-			stat.RunWalkHikePace = stat.RunWalkHikeTime / stat.RunWalkHikeDistance
+// requestLanguage memilih bahasa yang didukung dengan bobot q tertinggi dari Accept-Language
+// (mis. "en-US,en;q=0.9,id;q=0.8" -> "en"). Hanya subtag utama yang dibandingkan.
+func requestLanguage(c *gin.Context) string {
+	best := defaultLanguage
+	bestQ := 0.0
+	for _, part := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if _, ok := messageCatalog[lang]; !ok {
+			continue
 		}
 
-		// Bike Pace
-		if stat.BikeDistance > 0 {
-			stat.BikePace = stat.BikeTime / stat.BikeDistance
+		q := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
 		}
-
-		// Other Pace
-		if stat.OtherDistance > 0 {
-			stat.OtherPace = stat.OtherTime / stat.OtherDistance
+		if q > bestQ {
+			best, bestQ = lang, q
 		}
-
-		monthlyPaceStats = append(monthlyPaceStats, stat)
 	}
-
-	return monthlyPaceStats, nil
+	return best
 }
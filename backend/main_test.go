@@ -0,0 +1,1675 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// fixedClock adalah Clock palsu untuk pengujian yang selalu mengembalikan waktu yang sama.
+type fixedClock struct {
+	t time.Time
+}
+
+func (c fixedClock) Now() time.Time { return c.t }
+
+// memFileSystem adalah fileSystem di memori untuk pengujian. Error file tidak ditemukan
+// memenuhi os.IsNotExist seperti pada disk. Aman dipakai bersamaan dari beberapa goroutine.
+type memFileSystem struct {
+	mu    sync.Mutex
+	files map[string]memFile
+	dirs  map[string]bool
+}
+
+type memFile struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func newMemFileSystem() *memFileSystem {
+	return &memFileSystem{files: make(map[string]memFile), dirs: make(map[string]bool)}
+}
+
+func (m *memFileSystem) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	return bytes.Clone(file.data), nil
+}
+
+func (m *memFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	if dir := filepath.Dir(name); dir != "." && !m.dirs[dir] {
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	// modTime selalu maju agar cache memori (yang membandingkan modTime dan ukuran) mendeteksi
+	// penulisan ulang dengan ukuran sama meskipun jam tidak berubah di antara dua penulisan.
+	modTime := time.Now()
+	if previous, ok := m.files[name]; ok && !modTime.After(previous.modTime) {
+		modTime = previous.modTime.Add(time.Nanosecond)
+	}
+	m.files[name] = memFile{data: bytes.Clone(data), mode: perm.Perm(), modTime: modTime}
+	return nil
+}
+
+func (m *memFileSystem) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	if file, ok := m.files[name]; ok {
+		return memFileInfo{name: filepath.Base(name), size: int64(len(file.data)), mode: file.mode, modTime: file.modTime}, nil
+	}
+	if m.dirs[name] {
+		return memFileInfo{name: filepath.Base(name), mode: os.ModeDir | 0755}, nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func (m *memFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for dir := filepath.Clean(path); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+		m.dirs[dir] = true
+	}
+	return nil
+}
+
+func (m *memFileSystem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	if _, ok := m.files[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+func (m *memFileSystem) Open(name string) (io.ReadCloser, error) {
+	data, err := m.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name = filepath.Clean(name)
+	if !m.dirs[name] {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	var entries []os.DirEntry
+	for path, file := range m.files {
+		if filepath.Dir(path) == name {
+			info := memFileInfo{name: filepath.Base(path), size: int64(len(file.data)), mode: file.mode, modTime: file.modTime}
+			entries = append(entries, fs.FileInfoToDirEntry(info))
+		}
+	}
+	for dir := range m.dirs {
+		if filepath.Dir(dir) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(memFileInfo{name: filepath.Base(dir), mode: os.ModeDir | 0755}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *memFileSystem) RemoveAll(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path = filepath.Clean(path)
+	prefix := path + string(filepath.Separator)
+	for name := range m.files {
+		if name == path || strings.HasPrefix(name, prefix) {
+			delete(m.files, name)
+		}
+	}
+	for dir := range m.dirs {
+		if dir == path || strings.HasPrefix(dir, prefix) {
+			delete(m.dirs, dir)
+		}
+	}
+	return nil
+}
+
+func (m *memFileSystem) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	file, ok := m.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	delete(m.files, oldpath)
+	m.files[newpath] = file
+	return nil
+}
+
+// memFileInfo adalah os.FileInfo untuk entri memFileSystem.
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i memFileInfo) Name() string       { return i.name }
+func (i memFileInfo) Size() int64        { return i.size }
+func (i memFileInfo) Mode() os.FileMode  { return i.mode }
+func (i memFileInfo) ModTime() time.Time { return i.modTime }
+func (i memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memFileInfo) Sys() interface{}   { return nil }
+
+// rewriteTransport mengarahkan semua request (mis. ke www.strava.com) ke server uji.
+type rewriteTransport struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	req.Host = t.target.Host
+	return t.next.RoundTrip(req)
+}
+
+// useStravaServer menjalankan handler sebagai pengganti API Strava selama test berjalan.
+func useStravaServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := stravaClient
+	stravaClient = &http.Client{Transport: rewriteTransport{target: target, next: srv.Client().Transport}}
+	t.Cleanup(func() {
+		stravaClient = prev
+		srv.Close()
+	})
+	return srv
+}
+
+// useMemFS mengganti dataFS dengan sistem file di memori selama test berjalan.
+func useMemFS(t *testing.T) *memFileSystem {
+	t.Helper()
+	fs := newMemFileSystem()
+	prev := dataFS
+	dataFS = fs
+	t.Cleanup(func() { dataFS = prev })
+	return fs
+}
+
+// setTokens mengganti token di memori selama test berjalan.
+func setTokens(t *testing.T, tokens TokenData) {
+	t.Helper()
+	tokenMutex.Lock()
+	prev := currentTokens
+	currentTokens = tokens
+	tokenMutex.Unlock()
+	t.Cleanup(func() {
+		tokenMutex.Lock()
+		currentTokens = prev
+		tokenMutex.Unlock()
+	})
+}
+
+func TestEnsureValidTokenConcurrentRefreshOnce(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	useMemFS(t)
+	setTokens(t, TokenData{AccessToken: "lama", RefreshToken: "refresh", ExpiresAt: now.Add(-time.Minute).Unix()})
+
+	var hits atomic.Int32
+	useStravaServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" {
+			t.Errorf("path tidak terduga: %s", r.URL.Path)
+		}
+		hits.Add(1)
+		// Tahan respons agar semua goroutine sempat menunggu refresh yang sama
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"baru","refresh_token":"refresh2","expires_at":` +
+			strconv.FormatInt(now.Add(6*time.Hour).Unix(), 10) + `}`))
+	}))
+
+	s := newServer(Config{ClientID: "id", ClientSecret: "secret"})
+	s.clock = fixedClock{t: now}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	tokens := make([]string, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], errs[i] = s.ensureValidToken(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("endpoint refresh dipanggil %d kali, ingin 1", got)
+	}
+	for i := 0; i < callers; i++ {
+		if errs[i] != nil {
+			t.Fatalf("pemanggil %d: error %v", i, errs[i])
+		}
+		if tokens[i] != "baru" {
+			t.Errorf("pemanggil %d: token %q, ingin %q", i, tokens[i], "baru")
+		}
+	}
+}
+
+func TestFetchActivitiesPageRateLimitWaitHonorsContext(t *testing.T) {
+	prevDelay := rateLimitRetryDelay
+	rateLimitRetryDelay = time.Hour
+	t.Cleanup(func() { rateLimitRetryDelay = prevDelay })
+
+	useStravaServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Usage", "101,200")
+		w.Header().Set("X-RateLimit-Limit", "100,1000")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := fetchActivitiesPage(ctx, "token", 1, 10, 0, 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error %v, ingin context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("menunggu %s meski context sudah habis", elapsed)
+	}
+}
+
+// loadedTokens membaca token di memori di bawah tokenMutex.
+func loadedTokens() TokenData {
+	tokenMutex.Lock()
+	defer tokenMutex.Unlock()
+	return currentTokens
+}
+
+func TestTokenFileRoundTripMemFS(t *testing.T) {
+	fs := useMemFS(t)
+	setTokens(t, TokenData{})
+	want := TokenData{AccessToken: "akses", RefreshToken: "refresh", ExpiresAt: 1714564800, AthleteID: 42}
+
+	if err := saveToken(want); err != nil {
+		t.Fatalf("saveToken: %v", err)
+	}
+	info, err := fs.Stat(tokenFilePath)
+	if err != nil {
+		t.Fatalf("file token tidak ditulis: %v", err)
+	}
+	if info.Mode().Perm() != tokenFileMode {
+		t.Errorf("mode file token = %v, ingin %v", info.Mode().Perm(), tokenFileMode)
+	}
+
+	tokenMutex.Lock()
+	currentTokens = TokenData{}
+	tokenMutex.Unlock()
+	loadToken()
+	if got := loadedTokens(); got != want {
+		t.Errorf("loadToken = %+v, ingin %+v", got, want)
+	}
+
+	if err := clearToken(); err != nil {
+		t.Fatalf("clearToken: %v", err)
+	}
+	if _, err := fs.Stat(tokenFilePath); !os.IsNotExist(err) {
+		t.Errorf("file token masih ada setelah clearToken: %v", err)
+	}
+	if got := loadedTokens(); got != (TokenData{}) {
+		t.Errorf("token di memori = %+v setelah clearToken, ingin kosong", got)
+	}
+}
+
+func TestTokenEncryptionRoundTrip(t *testing.T) {
+	key := deriveTokenKey("rahasia")
+	want := TokenData{AccessToken: "akses", RefreshToken: "refresh", ExpiresAt: 1714564800, AthleteID: 42}
+
+	data, err := encryptTokenData(want, key)
+	if err != nil {
+		t.Fatalf("encryptTokenData: %v", err)
+	}
+	if bytes.Contains(data, []byte(want.AccessToken)) || bytes.Contains(data, []byte(want.RefreshToken)) {
+		t.Fatalf("file token berisi token teks biasa: %s", data)
+	}
+
+	got, err := decryptTokenData(data, key)
+	if err != nil {
+		t.Fatalf("decryptTokenData: %v", err)
+	}
+	if got != want {
+		t.Fatalf("hasil dekripsi %+v, ingin %+v", got, want)
+	}
+
+	t.Run("kunci salah", func(t *testing.T) {
+		if _, err := decryptTokenData(data, deriveTokenKey("kunci-lain")); err == nil {
+			t.Fatal("dekripsi dengan kunci salah seharusnya gagal")
+		}
+	})
+
+	t.Run("ciphertext diubah", func(t *testing.T) {
+		var envelope encryptedTokenFile
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			t.Fatal(err)
+		}
+		envelope.Ciphertext[0] ^= 0xff
+		tampered, err := json.Marshal(envelope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decryptTokenData(tampered, key); err == nil {
+			t.Fatal("dekripsi ciphertext yang diubah seharusnya gagal")
+		}
+	})
+}
+
+func TestPaceZoneForBoundaries(t *testing.T) {
+	const eps = 1e-9
+	for _, tc := range []struct {
+		name  string
+		zones PaceZoneConfig
+	}{
+		{"lari", defaultPaceZones},
+		{"jalan", defaultWalkPaceZones},
+	} {
+		z := tc.zones
+		cases := []struct {
+			speed float64
+			want  PaceZone
+		}{
+			{0, PaceZoneGreen},
+			{math.NaN(), PaceZoneGreen},
+			{z.Yellow - eps, PaceZoneGreen},
+			{z.Yellow, PaceZoneYellow},
+			{z.Yellow + eps, PaceZoneYellow},
+			{z.Orange - eps, PaceZoneYellow},
+			{z.Orange, PaceZoneOrange},
+			{z.Orange + eps, PaceZoneOrange},
+			{z.Red - eps, PaceZoneOrange},
+			{z.Red, PaceZoneRed},
+			{z.Red + eps, PaceZoneRed},
+		}
+		for _, c := range cases {
+			if got := paceZoneFor(c.speed, z); got != c.want {
+				t.Errorf("%s: paceZoneFor(%v) = %s, ingin %s", tc.name, c.speed, got.Key(), c.want.Key())
+			}
+		}
+	}
+
+	// Batas bawaan yang disebut di dokumentasi paceZoneFor
+	for speed, want := range map[float64]PaceZone{4.8: PaceZoneRed, 3.8: PaceZoneOrange, 3.0: PaceZoneYellow, 2.99: PaceZoneGreen} {
+		if got := paceZoneFor(speed, defaultPaceZones); got != want {
+			t.Errorf("paceZoneFor(%v) = %s, ingin %s", speed, got.Key(), want.Key())
+		}
+	}
+}
+
+func TestEnsureValidTokenExpiryMargin(t *testing.T) {
+	expiresAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	useMemFS(t)
+
+	var hits atomic.Int32
+	useStravaServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"baru","expires_at":` + strconv.FormatInt(expiresAt.Add(6*time.Hour).Unix(), 10) + `}`))
+	}))
+
+	// Margin bawaan 60 detik; batasnya inklusif
+	for _, tc := range []struct {
+		beforeExpiry time.Duration
+		wantRefresh  bool
+	}{
+		{61 * time.Second, false},
+		{60 * time.Second, true},
+		{59 * time.Second, true},
+	} {
+		hits.Store(0)
+		setTokens(t, TokenData{AccessToken: "lama", RefreshToken: "refresh", ExpiresAt: expiresAt.Unix()})
+
+		s := newServer(Config{})
+		s.clock = fixedClock{t: expiresAt.Add(-tc.beforeExpiry)}
+		token, err := s.ensureValidToken(context.Background())
+		if err != nil {
+			t.Fatalf("kedaluwarsa-%s: error %v", tc.beforeExpiry, err)
+		}
+
+		want := "lama"
+		if tc.wantRefresh {
+			want = "baru"
+		}
+		if token != want || (hits.Load() == 1) != tc.wantRefresh {
+			t.Errorf("kedaluwarsa-%s: token %q dengan %d refresh, ingin %q (refresh %v)",
+				tc.beforeExpiry, token, hits.Load(), want, tc.wantRefresh)
+		}
+	}
+}
+
+func TestStartOfWeekMonday(t *testing.T) {
+	// Minggu 29 April - 5 Mei 2024 melewati batas bulan
+	wantMonday := time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		t    time.Time
+	}{
+		{"Senin", time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC)},
+		{"Selasa", time.Date(2024, 4, 30, 7, 30, 0, 0, time.UTC)},
+		{"Rabu", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{"Kamis", time.Date(2024, 5, 2, 18, 45, 0, 0, time.UTC)},
+		{"Jumat", time.Date(2024, 5, 3, 6, 0, 0, 0, time.UTC)},
+		{"Sabtu", time.Date(2024, 5, 4, 21, 15, 0, 0, time.UTC)},
+		{"Minggu", time.Date(2024, 5, 5, 23, 59, 59, 0, time.UTC)},
+	} {
+		if got := startOfWeek(tc.t, time.Monday); !got.Equal(wantMonday) {
+			t.Errorf("%s %s: startOfWeek = %s, ingin %s", tc.name, tc.t.Format(time.RFC3339), got.Format("2006-01-02"), wantMonday.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestDefaultWeekRangeByWeekStart(t *testing.T) {
+	prev := weekStart
+	t.Cleanup(func() { weekStart = prev })
+
+	// Senin 29 April - Minggu 5 Mei 2024; dengan awal minggu hari Minggu,
+	// Minggu 5 Mei sudah masuk minggu berikutnya
+	monday := time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC)
+	sundayBefore := time.Date(2024, 4, 28, 0, 0, 0, 0, time.UTC)
+	sundayAfter := time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		day        string
+		now        time.Time
+		wantMonday time.Time
+		wantSunday time.Time
+	}{
+		{"Senin", time.Date(2024, 4, 29, 20, 0, 0, 0, time.UTC), monday, sundayBefore},
+		{"Selasa", time.Date(2024, 4, 30, 20, 0, 0, 0, time.UTC), monday, sundayBefore},
+		{"Rabu", time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC), monday, sundayBefore},
+		{"Kamis", time.Date(2024, 5, 2, 20, 0, 0, 0, time.UTC), monday, sundayBefore},
+		{"Jumat", time.Date(2024, 5, 3, 20, 0, 0, 0, time.UTC), monday, sundayBefore},
+		{"Sabtu", time.Date(2024, 5, 4, 20, 0, 0, 0, time.UTC), monday, sundayBefore},
+		{"Minggu", time.Date(2024, 5, 5, 20, 0, 0, 0, time.UTC), monday, sundayAfter},
+	} {
+		for first, want := range map[time.Weekday]time.Time{time.Monday: tc.wantMonday, time.Sunday: tc.wantSunday} {
+			weekStart = first
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/weekly-pace-stats", nil)
+
+			start, end, ok := parseWeekRangeQuery(c, time.UTC, tc.now)
+			if !ok {
+				t.Fatalf("%s/%s: parseWeekRangeQuery gagal", tc.day, first)
+			}
+			if !start.Equal(want) || !end.Equal(want.AddDate(0, 0, 6)) {
+				t.Errorf("%s/%s: rentang %s..%s, ingin %s..%s", tc.day, first,
+					start.Format("2006-01-02"), end.Format("2006-01-02"),
+					want.Format("2006-01-02"), want.AddDate(0, 0, 6).Format("2006-01-02"))
+			}
+		}
+	}
+}
+
+func TestHandleWebhookEventRejectsUnknownSubscription(t *testing.T) {
+	s := newServer(Config{})
+	router := gin.New()
+	router.POST("/api/webhook", s.handleWebhookEvent)
+
+	post := func(subscriptionID int64) int {
+		body := `{"object_type":"athlete","aspect_type":"delete","owner_id":7,"subscription_id":` + strconv.FormatInt(subscriptionID, 10) + `}`
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/webhook", strings.NewReader(body)))
+		return w.Code
+	}
+
+	// Subscription belum diketahui: semua event ditolak
+	if code := post(0); code != http.StatusForbidden {
+		t.Fatalf("subscription belum diketahui: status %d, ingin 403", code)
+	}
+
+	s.webhookSubscriptionID.Store(99)
+	if code := post(12345); code != http.StatusForbidden {
+		t.Fatalf("subscription_id salah: status %d, ingin 403", code)
+	}
+}
+
+func TestHandleDeauthorizationRequiresKnownAthlete(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		athleteID int64
+	}{
+		{"ID atlet belum diketahui", 0},
+		{"atlet lain", 8},
+	} {
+		setTokens(t, TokenData{AccessToken: "akses", RefreshToken: "refresh", AthleteID: tc.athleteID})
+		if err := handleDeauthorization(7); err != nil {
+			t.Fatalf("%s: error %v", tc.name, err)
+		}
+
+		tokenMutex.Lock()
+		accessToken := currentTokens.AccessToken
+		tokenMutex.Unlock()
+		if accessToken != "akses" {
+			t.Errorf("%s: token dihapus oleh deauthorization atlet 7", tc.name)
+		}
+	}
+}
+
+func TestRegisterWebhookSubscriptionStoresID(t *testing.T) {
+	useMemFS(t)
+	useStravaServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":99}`))
+	}))
+
+	s := newServer(Config{WebhookCallbackURL: "https://contoh.com/api/webhook"})
+	if err := s.registerWebhookSubscription(); err != nil {
+		t.Fatalf("registerWebhookSubscription: %v", err)
+	}
+	if got := s.webhookSubscriptionID.Load(); got != 99 {
+		t.Fatalf("subscription ID %d, ingin 99", got)
+	}
+	if got, err := loadWebhookSubscriptionID(); err != nil || got != 99 {
+		t.Fatalf("ID tersimpan %d (error %v), ingin 99", got, err)
+	}
+}
+
+func TestMergeActivities(t *testing.T) {
+	act := func(id float64, startDate, name string) map[string]interface{} {
+		return map[string]interface{}{"id": id, "start_date": startDate, "name": name}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		existing []map[string]interface{}
+		fetched  []map[string]interface{}
+		want     []string // nama aktivitas hasil, urut start_date
+	}{
+		{
+			name:     "tumpang tindih",
+			existing: []map[string]interface{}{act(1, "2024-05-01T06:00:00Z", "a"), act(2, "2024-05-02T06:00:00Z", "b")},
+			fetched:  []map[string]interface{}{act(2, "2024-05-02T06:00:00Z", "b"), act(3, "2024-05-03T06:00:00Z", "c")},
+			want:     []string{"a", "b", "c"},
+		},
+		{
+			name:     "hanya aktivitas baru",
+			existing: []map[string]interface{}{act(2, "2024-05-02T06:00:00Z", "b")},
+			fetched:  []map[string]interface{}{act(3, "2024-05-03T06:00:00Z", "c"), act(1, "2024-05-01T06:00:00Z", "a")},
+			want:     []string{"a", "b", "c"},
+		},
+		{
+			name:     "aktivitas diedit",
+			existing: []map[string]interface{}{act(1, "2024-05-01T06:00:00Z", "lama"), act(2, "2024-05-02T06:00:00Z", "b")},
+			fetched:  []map[string]interface{}{act(1, "2024-05-01T06:00:00Z", "baru")},
+			want:     []string{"baru", "b"},
+		},
+	} {
+		merged := mergeActivities(tc.existing, tc.fetched)
+
+		var got []string
+		for _, activity := range merged {
+			got = append(got, activity["name"].(string))
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("%s: hasil %v, ingin %v", tc.name, got, tc.want)
+		}
+		if !sort.SliceIsSorted(merged, func(i, j int) bool {
+			return merged[i]["start_date"].(string) < merged[j]["start_date"].(string)
+		}) {
+			t.Errorf("%s: hasil tidak urut start_date", tc.name)
+		}
+	}
+}
+
+// useDataDir mengarahkan semua path file data ke direktori sementara selama test berjalan.
+func useDataDir(t *testing.T) {
+	t.Helper()
+	prev := dataDir
+	setDataDir(t.TempDir())
+	invalidateActivityCache()
+	t.Cleanup(func() {
+		setDataDir(prev)
+		invalidateActivityCache()
+	})
+}
+
+func TestWeeklyStatsReportIncompleteCoverageOffline(t *testing.T) {
+	useDataDir(t)
+	if err := saveActivitiesFile([]map[string]interface{}{
+		{"id": 1.0, "type": "Run", "distance": 5000.0, "moving_time": 1500.0,
+			"start_date": "2024-05-02T06:00:00Z", "start_date_local": "2024-05-02T06:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveCacheCoverage(cacheCoverage{CoveredFrom: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Token kedaluwarsa: endpoint statistik tidak boleh me-refresh token atau menghubungi Strava
+	setTokens(t, TokenData{AccessToken: "lama", RefreshToken: "refresh", ExpiresAt: 1})
+	var hits atomic.Int32
+	useStravaServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	s := newServer(Config{})
+	router := gin.New()
+	router.GET("/api/weekly-pace-stats", s.handleGetWeeklyPaceStats)
+	router.GET("/api/weekly-distance-stats", s.handleGetWeeklyDistanceStats)
+
+	for _, target := range []string{
+		"/api/weekly-pace-stats?startDate=2024-04-29&endDate=2024-05-05",
+		"/api/weekly-distance-stats?startDate=2024-04-29&endDate=2024-05-05",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", target, w.Code, w.Body)
+		}
+		if w.Header().Get("X-Cache-Incomplete") != "true" || w.Header().Get("X-Cache-Covered-From") != "2024-05-01T00:00:00Z" {
+			t.Errorf("%s: header cakupan %v", target, w.Header())
+		}
+	}
+	if hits.Load() != 0 {
+		t.Fatalf("endpoint statistik menghubungi Strava %d kali", hits.Load())
+	}
+
+	// Rentang yang sepenuhnya tercakup tidak ditandai
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/weekly-pace-stats?startDate=2024-05-06&endDate=2024-05-12", nil))
+	var body GlobalWeeklyData
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.CoverageIncomplete || w.Header().Get("X-Cache-Incomplete") != "" {
+		t.Errorf("rentang tercakup ditandai tidak lengkap")
+	}
+}
+
+func TestCacheCoverageTracksSyncedWindow(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	useStravaServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"id":1,"start_date":"2024-02-01T06:00:00Z"}]`))
+	}))
+
+	for _, tc := range []struct {
+		name   string
+		window syncWindow
+		want   cacheCoverage
+	}{
+		{"hanya before", syncWindow{before: mar}, cacheCoverage{CoveredUntil: mar}},
+		{"after dan before", syncWindow{after: jan, before: mar}, cacheCoverage{CoveredFrom: jan, CoveredUntil: mar}},
+		{"hanya after", syncWindow{after: jan}, cacheCoverage{CoveredFrom: jan}},
+	} {
+		useDataDir(t)
+		if err := fetchAndMergeActivityWindow(context.Background(), "token", tc.window); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		got, err := loadCacheCoverage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.CoveredFrom.Equal(tc.want.CoveredFrom) || !got.CoveredUntil.Equal(tc.want.CoveredUntil) {
+			t.Errorf("%s: cakupan %+v, ingin %+v", tc.name, got, tc.want)
+		}
+		// Aktivitas setelah before tidak pernah diambil, sehingga Mei tidak boleh dianggap tercakup
+		if tc.window.before.IsZero() != got.covers(may, may.AddDate(0, 0, 7)) {
+			t.Errorf("%s: covers(Mei) = %v", tc.name, got.covers(may, may.AddDate(0, 0, 7)))
+		}
+	}
+
+	// Sinkronisasi inkremental setelahnya mengambil semua aktivitas hingga sekarang
+	useDataDir(t)
+	if err := fetchAndMergeActivityWindow(context.Background(), "token", syncWindow{after: jan, before: mar}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fetchAndMergeNewActivities(context.Background(), "token"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := loadCacheCoverage(); !got.CoveredUntil.IsZero() || !got.CoveredFrom.Equal(jan) {
+		t.Errorf("setelah sinkronisasi inkremental: cakupan %+v", got)
+	}
+}
+
+func TestCacheCoverageExtend(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	apr := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name   string
+		cov    cacheCoverage
+		window syncWindow
+		want   cacheCoverage
+	}{
+		{"seluruh riwayat tetap", cacheCoverage{}, syncWindow{after: feb}, cacheCoverage{}},
+		{"bersambung di awal", cacheCoverage{CoveredFrom: feb}, syncWindow{after: jan, before: feb}, cacheCoverage{CoveredFrom: jan}},
+		{"celah di awal", cacheCoverage{CoveredFrom: mar}, syncWindow{after: jan, before: feb}, cacheCoverage{CoveredFrom: mar}},
+		{"bersambung di akhir", cacheCoverage{CoveredFrom: jan, CoveredUntil: feb}, syncWindow{after: feb, before: mar}, cacheCoverage{CoveredFrom: jan, CoveredUntil: mar}},
+		{"celah di akhir", cacheCoverage{CoveredFrom: jan, CoveredUntil: feb}, syncWindow{after: mar, before: apr}, cacheCoverage{CoveredFrom: jan, CoveredUntil: feb}},
+		{"hingga sekarang", cacheCoverage{CoveredFrom: jan, CoveredUntil: feb}, syncWindow{after: feb}, cacheCoverage{CoveredFrom: jan}},
+		{"hanya before", cacheCoverage{CoveredFrom: feb}, syncWindow{before: mar}, cacheCoverage{}},
+	} {
+		got := tc.cov.extend(tc.window)
+		if !got.CoveredFrom.Equal(tc.want.CoveredFrom) || !got.CoveredUntil.Equal(tc.want.CoveredUntil) {
+			t.Errorf("%s: %+v, ingin %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestHandleStravaLoginEscapesParams(t *testing.T) {
+	s := newServer(Config{
+		ClientID:    "123",
+		RedirectURI: "http://localhost:8080/strava-callback?next=a b&x=1",
+		Scope:       "read,activity:read_all",
+	})
+	router := gin.New()
+	router.GET("/api/auth/strava", s.handleStravaLogin)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/strava", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("status %d, ingin 302", w.Code)
+	}
+
+	location := w.Header().Get("Location")
+	authURL, err := url.Parse(location)
+	if err != nil {
+		t.Fatalf("Location tidak dapat diurai: %v", err)
+	}
+	if authURL.Scheme != "https" || authURL.Host != "www.strava.com" || authURL.Path != "/oauth/authorize" {
+		t.Errorf("URL otorisasi %q", location)
+	}
+
+	// Nilai asli kembali utuh setelah diurai, dan karakter khusus tidak muncul mentah di query
+	query := authURL.Query()
+	if got := query.Get("redirect_uri"); got != s.cfg.RedirectURI {
+		t.Errorf("redirect_uri %q, ingin %q", got, s.cfg.RedirectURI)
+	}
+	if got := query.Get("scope"); got != s.cfg.Scope {
+		t.Errorf("scope %q, ingin %q", got, s.cfg.Scope)
+	}
+	for _, escaped := range []string{
+		"redirect_uri=http%3A%2F%2Flocalhost%3A8080%2Fstrava-callback%3Fnext%3Da+b%26x%3D1",
+		"scope=read%2Cactivity%3Aread_all",
+	} {
+		if !strings.Contains(authURL.RawQuery, escaped) {
+			t.Errorf("query %q tidak berisi %q", authURL.RawQuery, escaped)
+		}
+	}
+}
+
+func TestHandleSearchActivities(t *testing.T) {
+	useDataDir(t)
+	if err := saveActivitiesFile([]map[string]interface{}{
+		{"id": 1.0, "name": "Tempo Run", "type": "Run", "start_date": "2024-05-01T06:00:00Z"},
+		{"id": 2.0, "name": "Easy ride", "type": "Ride", "start_date": "2024-05-02T06:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newServer(Config{})
+	router := gin.New()
+	router.GET("/api/activities/search", s.handleSearchActivities)
+
+	for target, wantIDs := range map[string][]float64{
+		"/api/activities/search?q=TEMPO":           {1},
+		"/api/activities/search?q=tempo&type=Ride": {},
+		"/api/activities/search?q=intervals":       {},
+		"/api/activities/search?q=":                {},
+		"/api/activities/search":                   {},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", target, w.Code, w.Body)
+		}
+		var got []map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got == nil {
+			t.Fatalf("%s: body %s bukan array (error %v)", target, w.Body, err)
+		}
+		if len(got) != len(wantIDs) {
+			t.Errorf("%s: %d aktivitas, ingin %d", target, len(got), len(wantIDs))
+			continue
+		}
+		for i, id := range wantIDs {
+			if got[i]["id"] != id {
+				t.Errorf("%s: aktivitas %d id %v, ingin %v", target, i, got[i]["id"], id)
+			}
+		}
+	}
+}
+
+// useAppLocation mengganti zona waktu aplikasi selama test berjalan.
+func useAppLocation(t *testing.T, name string) {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("zona waktu %s tidak tersedia: %v", name, err)
+	}
+	prev := appLocation
+	appLocation = loc
+	t.Cleanup(func() { appLocation = prev })
+}
+
+func TestRollingAndStreaksUseAppTimezoneToday(t *testing.T) {
+	useDataDir(t)
+	useAppLocation(t, "Asia/Jakarta")
+	if err := saveActivitiesFile([]map[string]interface{}{
+		{"id": 1.0, "type": "Run", "distance": 5000.0, "moving_time": 1500.0,
+			"start_date": "2024-05-01T23:30:00Z", "start_date_local": "2024-05-02T06:30:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 1 Mei 23:45 UTC sudah 2 Mei 06:45 di Jakarta
+	s := newServer(Config{})
+	s.clock = fixedClock{t: time.Date(2024, 5, 1, 23, 45, 0, 0, time.UTC)}
+	router := gin.New()
+	router.GET("/api/rolling-stats", s.handleGetRollingStats)
+	router.GET("/api/streaks", s.handleGetStreaks)
+
+	var rolling RollingStats
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/rolling-stats", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &rolling); err != nil {
+		t.Fatalf("rolling-stats: %v (%s)", err, w.Body)
+	}
+	if rolling.AsOf != "2024-05-02" || rolling.Windows[0].RunWalkHike != 5000 {
+		t.Errorf("rolling-stats: as_of %s, jarak 7 hari %v; ingin 2024-05-02 dan 5000", rolling.AsOf, rolling.Windows[0].RunWalkHike)
+	}
+
+	var streaks StreakStats
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/streaks", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &streaks); err != nil {
+		t.Fatalf("streaks: %v (%s)", err, w.Body)
+	}
+	if streaks.AsOf != "2024-05-02" || streaks.CurrentStreak != 1 {
+		t.Errorf("streaks: as_of %s, streak %d; ingin 2024-05-02 dan 1", streaks.AsOf, streaks.CurrentStreak)
+	}
+}
+
+func TestPeriodStatsBucketByLocalStartDate(t *testing.T) {
+	useDataDir(t)
+	// Tahun baru di Jakarta, masih 31 Desember menurut start_date (UTC)
+	if err := saveActivitiesFile([]map[string]interface{}{
+		{"id": 1.0, "type": "Run", "distance": 10000.0, "moving_time": 3000.0, "total_elevation_gain": 50.0, "kudos_count": 3.0,
+			"start_date": "2023-12-31T18:00:00Z", "start_date_local": "2024-01-01T01:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	yearly, err := calculateYearlyDistanceStats(defaultStatsFilter)
+	if err != nil || len(yearly) != 1 || yearly[0].Year != "2024" {
+		t.Errorf("yearly-stats: %+v (error %v), ingin tahun 2024", yearly, err)
+	}
+	social, err := calculateMonthlySocialStats(defaultStatsFilter)
+	if err != nil || len(social) != 1 || social[0].MonthYear != "2024-01" {
+		t.Errorf("social-stats: %+v (error %v), ingin bulan 2024-01", social, err)
+	}
+	if climb := calculateMonthlyClimbStats(defaultStatsFilter); len(climb) != 1 || climb[0].MonthYear != "2024-01" {
+		t.Errorf("climb-stats: %+v, ingin bulan 2024-01", climb)
+	}
+	if trend := calculatePaceTrend(loadLocalActivities(defaultStatsFilter), "Run", PaceZoneYellow); len(trend) != 1 || trend[0].MonthYear != "2024-01" {
+		t.Errorf("pace-trend: %+v, ingin bulan 2024-01", trend)
+	}
+	periods := calculateAvailablePeriods(defaultStatsFilter)
+	if strings.Join(periods.Years, ",") != "2024" || strings.Join(periods.Months, ",") != "2024-01" {
+		t.Errorf("available-periods: %+v, ingin 2024 dan 2024-01", periods)
+	}
+	summary, err := calculateLifetimeSummary(defaultStatsFilter)
+	if err != nil || !strings.HasPrefix(summary.FirstActivityDate, "2024-01-01") {
+		t.Errorf("summary: first_activity_date %q (error %v), ingin 2024-01-01", summary.FirstActivityDate, err)
+	}
+}
+
+func TestActivitiesFileMigrationMemFS(t *testing.T) {
+	fs := useMemFS(t)
+	invalidateActivityCache()
+	t.Cleanup(invalidateActivityCache)
+
+	if err := fs.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := `[{"id": 1, "name": "Lari pagi"}]`
+	if err := fs.WriteFile(legacyDataFilePath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	readIDs := func(t *testing.T) []float64 {
+		t.Helper()
+		activities, err := readRawActivities()
+		if err != nil {
+			t.Fatalf("readRawActivities: %v", err)
+		}
+		var ids []float64
+		for _, activity := range activities {
+			ids = append(ids, activity["id"].(float64))
+		}
+		return ids
+	}
+	openAll := func(t *testing.T) string {
+		t.Helper()
+		r, err := openActivitiesFile()
+		if err != nil {
+			t.Fatalf("openActivitiesFile: %v", err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("gagal membaca cache: %v", err)
+		}
+		return string(data)
+	}
+
+	if got := readIDs(t); len(got) != 1 || got[0] != 1 {
+		t.Errorf("aktivitas dari file lama = %v, ingin [1]", got)
+	}
+	if got := openAll(t); got != legacy {
+		t.Errorf("openActivitiesFile (file lama) = %q, ingin %q", got, legacy)
+	}
+
+	if err := saveActivitiesFile([]map[string]interface{}{{"id": 1}, {"id": 2}}); err != nil {
+		t.Fatalf("saveActivitiesFile: %v", err)
+	}
+	if _, err := fs.Stat(legacyDataFilePath); !os.IsNotExist(err) {
+		t.Errorf("file lama masih ada setelah migrasi: %v", err)
+	}
+	compressed, err := fs.ReadFile(dataFilePath)
+	if err != nil {
+		t.Fatalf("file gzip tidak ditulis: %v", err)
+	}
+	if len(compressed) < 2 || compressed[0] != 0x1f || compressed[1] != 0x8b {
+		t.Errorf("file cache bukan gzip: % x", compressed[:min(len(compressed), 4)])
+	}
+	if got := readIDs(t); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("aktivitas dari file gzip = %v, ingin [1 2]", got)
+	}
+	if got := openAll(t); !strings.Contains(got, `"id": 2`) {
+		t.Errorf("openActivitiesFile tidak mendekompresi file gzip: %q", got)
+	}
+
+	if err := backupCorruptCache(context.Background()); err != nil {
+		t.Fatalf("backupCorruptCache: %v", err)
+	}
+	if _, err := fs.Stat(dataFilePath + ".bak"); err != nil {
+		t.Errorf("cadangan cache tidak dibuat: %v", err)
+	}
+	if _, err := readRawActivities(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("readRawActivities setelah pencadangan: error = %v, ingin os.ErrNotExist", err)
+	}
+}
+
+func TestCheckDataDirWritableMemFS(t *testing.T) {
+	fs := useMemFS(t)
+
+	if err := checkDataDirWritable(); err != nil {
+		t.Fatalf("checkDataDirWritable: %v", err)
+	}
+	if info, err := fs.Stat(dataDir); err != nil || !info.IsDir() {
+		t.Errorf("direktori data tidak dibuat: %v", err)
+	}
+	if len(fs.files) != 0 {
+		t.Errorf("file uji tertinggal: %v", fs.files)
+	}
+}
+
+func TestStatusResponseJSONContract(t *testing.T) {
+	age := int64(30)
+	data, err := json.Marshal(StatusResponse{
+		Status:          "OK",
+		DataFile:        "data/strava_activities.json.gz",
+		FileStatus:      "OK",
+		CacheAgeSeconds: &age,
+		CacheUpdatedAt:  "01 May 24 12:00 UTC",
+		TokenValid:      true,
+		TokenExpires:    "01 May 24 18:00 UTC",
+		HasRefreshToken: true,
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	// Nama field dipakai frontend; token_status dan refresh_token sengaja tidak mengikuti nama field Go.
+	want := map[string]string{
+		"status":            "string",
+		"data_file":         "string",
+		"file_status":       "string",
+		"cache_age_seconds": "number",
+		"cache_updated_at":  "string",
+		"cache_stale":       "bool",
+		"token_status":      "bool",
+		"token_expires":     "string",
+		"refresh_token":     "bool",
+	}
+	if len(got) != len(want) {
+		t.Errorf("jumlah field = %d, ingin %d: %s", len(got), len(want), data)
+	}
+	for key, kind := range want {
+		value, ok := got[key]
+		if !ok {
+			t.Errorf("field %q tidak ada: %s", key, data)
+			continue
+		}
+		var gotKind string
+		switch value.(type) {
+		case string:
+			gotKind = "string"
+		case bool:
+			gotKind = "bool"
+		case float64:
+			gotKind = "number"
+		}
+		if gotKind != kind {
+			t.Errorf("field %q bertipe %T, ingin %s", key, value, kind)
+		}
+	}
+
+	data, err = json.Marshal(StatusResponse{})
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	if !bytes.Contains(data, []byte(`"cache_age_seconds":null`)) {
+		t.Errorf("cache_age_seconds tanpa file data harus null: %s", data)
+	}
+}
+
+// readCountingFS mencatat setiap path yang dibaca melalui ReadFile.
+type readCountingFS struct {
+	*memFileSystem
+	mu    sync.Mutex
+	reads []string
+}
+
+func (f *readCountingFS) ReadFile(name string) ([]byte, error) {
+	f.mu.Lock()
+	f.reads = append(f.reads, name)
+	f.mu.Unlock()
+	return f.memFileSystem.ReadFile(name)
+}
+
+func TestWeeklyPaceDataReadsOnlyCachedStreams(t *testing.T) {
+	counting := &readCountingFS{memFileSystem: useMemFS(t)}
+	dataFS = counting
+
+	// Aktivitas 7 (interval): 500 m di 5 m/s (merah) lalu 500 m di 2.5 m/s (hijau)
+	streams := activityStreams{}
+	streams.Distance.Data = []float64{0, 500, 1000}
+	streams.VelocitySmooth.Data = []float64{5, 5, 2.5}
+	if err := saveCachedVelocityStream(7, streams); err != nil {
+		t.Fatalf("saveCachedVelocityStream: %v", err)
+	}
+	// File sementara sisa penulisan atomik bukan stream tersimpan
+	if err := counting.WriteFile(filepath.Join(streamsDir, "9.json.123.tmp"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	activities := []StravaActivity{
+		{ID: 7, Type: "Run", Distance: 1000, MovingTime: 300, StartDateLocal: "2024-04-29T07:00:00Z"},
+		{ID: 8, Type: "Run", Distance: 1000, MovingTime: 300, StartDateLocal: "2024-04-30T07:00:00Z"},
+		{ID: 9, Type: "Run", Distance: 1000, MovingTime: 300, StartDateLocal: "2024-05-01T07:00:00Z"},
+	}
+	start := time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC)
+	data, _ := buildWeeklyPaceData(activities, start, start.AddDate(0, 0, 6), time.UTC, unitMetric)
+
+	if got, want := data["2024-04-29"], (PaceStat{Red: 0.5, Green: 0.5}); math.Abs(got.Red-want.Red) > 1e-9 || math.Abs(got.Green-want.Green) > 1e-9 || got.Orange != 0 || got.Yellow != 0 {
+		t.Errorf("aktivitas dengan stream = %+v, ingin %+v", got, want)
+	}
+	// 1000 m dalam 300 s = 3.33 m/s, seluruhnya di zona kuning
+	for _, day := range []string{"2024-04-30", "2024-05-01"} {
+		if got, want := data[day], (PaceStat{Yellow: 1}); got != want {
+			t.Errorf("aktivitas tanpa stream pada %s = %+v, ingin %+v", day, got, want)
+		}
+	}
+	if want := []string{streamsFilePath(7)}; len(counting.reads) != 1 || counting.reads[0] != want[0] {
+		t.Errorf("file yang dibaca = %v, ingin %v", counting.reads, want)
+	}
+}
+
+func TestFetchAndSaveAllActivitiesDropsDuplicateIDs(t *testing.T) {
+	useMemFS(t)
+	invalidateActivityCache()
+	t.Cleanup(invalidateActivityCache)
+	prevPerPage := stravaPerPage
+	stravaPerPage = 2
+	t.Cleanup(func() { stravaPerPage = prevPerPage })
+
+	// Aktivitas 2 tergeser ke halaman 2 karena aktivitas baru ditambahkan selama paginasi
+	pages := map[string]string{
+		"1": `[{"id": 1, "name": "A", "start_date": "2024-05-01T07:00:00Z"}, {"id": 2, "name": "B", "start_date": "2024-04-30T07:00:00Z"}]`,
+		"2": `[{"id": 2, "name": "B (diedit)", "start_date": "2024-04-30T07:00:00Z"}, {"id": 3, "name": "C", "start_date": "2024-04-29T07:00:00Z"}]`,
+	}
+	useStravaServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Query().Get("page")]
+		if !ok {
+			page = `[]`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(page))
+	}))
+
+	if err := fetchAndSaveAllActivities(context.Background(), "akses"); err != nil {
+		t.Fatalf("fetchAndSaveAllActivities: %v", err)
+	}
+
+	saved, err := readRawActivities()
+	if err != nil {
+		t.Fatalf("readRawActivities: %v", err)
+	}
+	counts := make(map[float64]int)
+	names := make(map[float64]string)
+	for _, activity := range saved {
+		id := activity["id"].(float64)
+		counts[id]++
+		names[id], _ = activity["name"].(string)
+	}
+	if len(saved) != 3 {
+		t.Errorf("jumlah aktivitas tersimpan = %d, ingin 3: %v", len(saved), saved)
+	}
+	for _, id := range []float64{1, 2, 3} {
+		if counts[id] != 1 {
+			t.Errorf("aktivitas %v tersimpan %d kali, ingin 1", id, counts[id])
+		}
+	}
+	if names[2] != "B (diedit)" {
+		t.Errorf("nama aktivitas 2 = %q, ingin versi terakhir %q", names[2], "B (diedit)")
+	}
+}
+
+func TestWeeklySummaryUnits(t *testing.T) {
+	start := time.Date(2024, 4, 29, 0, 0, 0, 0, time.UTC)
+	// Tepat 1 mil dalam 8 menit
+	activities := []StravaActivity{{ID: 1, Type: "Run", Distance: metersPerMile, MovingTime: 480, StartDateLocal: "2024-04-29T07:00:00Z"}}
+
+	tests := []struct {
+		unit               string
+		wantDistance       float64
+		wantPace           float64
+		wantDistanceKM     float64
+		wantPaceSecPerM    float64
+		wantJSONDistance   string
+		wantJSONDistanceKM string
+	}{
+		{unitMetric, metersPerMile / 1000, 480 / metersPerMile, metersPerMile / 1000, 480 / metersPerMile, "1.609344", "1.609344"},
+		{unitImperial, 1, 8, metersPerMile / 1000, 480 / metersPerMile, "1", "1.609344"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.unit, func(t *testing.T) {
+			_, summary := buildWeeklyPaceData(activities, start, start.AddDate(0, 0, 6), time.UTC, tt.unit)
+			for _, check := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"total_distance", summary.TotalDistance, tt.wantDistance},
+				{"average_pace", summary.AveragePace, tt.wantPace},
+				{"total_distance_km", summary.TotalDistanceKM, tt.wantDistanceKM},
+				{"average_pace_sec_per_m", summary.AveragePaceSecPerM, tt.wantPaceSecPerM},
+			} {
+				if math.Abs(check.got-check.want) > 1e-9 {
+					t.Errorf("%s = %v, ingin %v", check.name, check.got, check.want)
+				}
+			}
+
+			data, err := json.Marshal(summary)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			for _, key := range []string{`"total_distance":` + tt.wantJSONDistance + `,`, `"total_distance_km":` + tt.wantJSONDistanceKM + `,`} {
+				if !bytes.Contains(data, []byte(key)) {
+					t.Errorf("JSON %s tidak berisi %s", data, key)
+				}
+			}
+		})
+	}
+}
+
+func TestActivityFilterUsesLocalStartDate(t *testing.T) {
+	useAppLocation(t, "Asia/Jakarta")
+
+	// 20:00 UTC tanggal 29 adalah pukul 03:00 tanggal 30 di lokasi aktivitas
+	raw := []map[string]interface{}{{"id": float64(1), "start_date": "2024-04-29T20:00:00Z", "start_date_local": "2024-04-30T03:00:00Z"}}
+	typed := []StravaActivity{{ID: 1, StartDate: "2024-04-29T20:00:00Z", StartDateLocal: "2024-04-30T03:00:00Z"}}
+	// Tanpa start_date_local, start_date dikonversi ke APP_TIMEZONE (17:00 UTC = 00:00 WIB tanggal 30)
+	noLocal := []StravaActivity{{ID: 2, StartDate: "2024-04-29T17:00:00Z"}}
+
+	for _, tc := range []struct {
+		day  string
+		want int
+	}{
+		{"2024-04-29", 0},
+		{"2024-04-30", 1},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/activities?startDate="+tc.day+"&endDate="+tc.day, nil)
+		filter, ok := parseActivityFilter(c)
+		if !ok {
+			t.Fatalf("%s: parseActivityFilter gagal", tc.day)
+		}
+
+		if got := len(filter.apply(raw)); got != tc.want {
+			t.Errorf("%s: apply = %d aktivitas, ingin %d", tc.day, got, tc.want)
+		}
+		if got := len(filter.applyTyped(typed)); got != tc.want {
+			t.Errorf("%s: applyTyped = %d aktivitas, ingin %d", tc.day, got, tc.want)
+		}
+		if got := len(filter.applyTyped(noLocal)); got != tc.want {
+			t.Errorf("%s: applyTyped tanpa start_date_local = %d aktivitas, ingin %d", tc.day, got, tc.want)
+		}
+	}
+}
+
+func TestWriteFileAtomicConcurrentWriters(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gear.json")
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- writeFileAtomic(path, []byte(strings.Repeat(strconv.Itoa(i%10), 1000)), 0640)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("writeFileAtomic: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1000 || strings.Count(string(data), string(data[0])) != 1000 {
+		t.Errorf("isi file tercampur antar penulis: %.40q...", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode file = %v, ingin %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("file sementara tertinggal: %v", names)
+	}
+}
+
+func TestRawActivityEndpointsServeFromMemory(t *testing.T) {
+	counting := &readCountingFS{memFileSystem: useMemFS(t)}
+	dataFS = counting
+	invalidateActivityCache()
+	t.Cleanup(invalidateActivityCache)
+	if err := saveActivitiesFile([]map[string]interface{}{
+		{"id": 1.0, "name": "Tempo Run", "type": "Run", "start_date": "2024-05-01T06:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newServer(Config{})
+	router := gin.New()
+	router.GET("/api/activities/recent", s.handleGetRecentActivities)
+	router.GET("/api/activities/search", s.handleSearchActivities)
+	router.GET("/api/activities/:id", s.handleGetActivityByID)
+
+	get := func(target string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", target, w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+	cacheReads := func() int {
+		counting.mu.Lock()
+		defer counting.mu.Unlock()
+		n := 0
+		for _, name := range counting.reads {
+			if name == dataFilePath {
+				n++
+			}
+		}
+		return n
+	}
+
+	for range 3 {
+		get("/api/activities/recent")
+		get("/api/activities/search?q=tempo")
+		get("/api/activities/1")
+	}
+	if got := cacheReads(); got != 1 {
+		t.Errorf("file cache dibaca %d kali untuk 9 request, ingin 1", got)
+	}
+
+	// Cache yang ditulis ulang dibaca kembali satu kali
+	if err := saveActivitiesFile([]map[string]interface{}{
+		{"id": 1.0, "name": "Tempo Run", "type": "Run", "start_date": "2024-05-01T06:00:00Z"},
+		{"id": 2.0, "name": "Long Run", "type": "Run", "start_date": "2024-05-02T06:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if body := get("/api/activities/search?q=long"); !strings.Contains(body, `"Long Run"`) {
+		t.Errorf("cache memori tidak dimuat ulang setelah file berubah: %s", body)
+	}
+	if got := cacheReads(); got != 2 {
+		t.Errorf("file cache dibaca %d kali setelah diperbarui, ingin 2", got)
+	}
+}
+
+func TestWeeklyStatsBackfillUncoveredRangeWithValidToken(t *testing.T) {
+	useDataDir(t)
+	if err := saveActivitiesFile([]map[string]interface{}{
+		{"id": 1.0, "type": "Run", "distance": 5000.0, "moving_time": 1500.0,
+			"start_date": "2024-05-02T06:00:00Z", "start_date_local": "2024-05-02T06:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	coveredFrom := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	if err := saveCacheCoverage(cacheCoverage{CoveredFrom: coveredFrom}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	setTokens(t, TokenData{AccessToken: "akses", RefreshToken: "refresh", ExpiresAt: now.Add(time.Hour).Unix()})
+	var queries []url.Values
+	var mu sync.Mutex
+	useStravaServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/athlete/activities" {
+			t.Errorf("request tak terduga ke %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		queries = append(queries, r.URL.Query())
+		mu.Unlock()
+		if r.URL.Query().Get("page") != "1" {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(`[{"id": 2, "type": "Run", "distance": 10000, "moving_time": 3000,
+			"start_date": "2024-04-30T06:00:00Z", "start_date_local": "2024-04-30T06:00:00Z"}]`))
+	}))
+
+	s := newServer(Config{})
+	s.clock = fixedClock{t: now}
+	router := gin.New()
+	router.GET("/api/weekly-pace-stats", s.handleGetWeeklyPaceStats)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/weekly-pace-stats?startDate=2024-04-29&endDate=2024-05-05", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if w.Header().Get("X-Cache-Incomplete") != "" {
+		t.Errorf("rentang yang sudah di-backfill masih ditandai tidak lengkap: %v", w.Header())
+	}
+	var body GlobalWeeklyData
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.CoverageIncomplete {
+		t.Errorf("coverage_incomplete = true setelah backfill")
+	}
+	if got := body.Summary.TotalDistanceKM; got != 15 {
+		t.Errorf("total_distance_km = %v, ingin 15 (termasuk aktivitas hasil backfill)", got)
+	}
+
+	// Hanya rentang sebelum covered_from yang diambil (satu hari lebih awal dari startDate)
+	wantAfter := strconv.FormatInt(time.Date(2024, 4, 28, 0, 0, 0, 0, time.UTC).Unix(), 10)
+	wantBefore := strconv.FormatInt(coveredFrom.Unix(), 10)
+	if len(queries) == 0 || queries[0].Get("after") != wantAfter || queries[0].Get("before") != wantBefore {
+		t.Errorf("query backfill = %v, ingin after=%s before=%s", queries, wantAfter, wantBefore)
+	}
+
+	activitiesFileMutex.Lock()
+	coverage, err := loadCacheCoverage()
+	activitiesFileMutex.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 4, 28, 0, 0, 0, 0, time.UTC); !coverage.CoveredFrom.Equal(want) {
+		t.Errorf("covered_from = %v, ingin %v", coverage.CoveredFrom, want)
+	}
+
+	// Rentang yang kini tercakup tidak memicu backfill lagi
+	requests := len(queries)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/weekly-pace-stats?startDate=2024-04-29&endDate=2024-05-05", nil))
+	if len(queries) != requests {
+		t.Errorf("backfill diulang untuk rentang yang sudah tercakup: %d request", len(queries)-requests)
+	}
+}
+
+func TestClassifyActivityRunTypes(t *testing.T) {
+	prevRunTypes := runTypes
+	t.Cleanup(func() {
+		runTypes = prevRunTypes
+		setClassificationOverrides(nil)
+	})
+
+	runTypes = map[string]bool{"Run": true, "TrailRun": true, "VirtualRun": true, "Workout": true}
+	setClassificationOverrides(map[string]string{"TrailRun": "Other"})
+
+	for activityType, want := range map[string]string{
+		"Run":        "RunWalkHike",
+		"VirtualRun": "RunWalkHike",
+		"Workout":    "RunWalkHike", // Tipe tambahan dari RUN_TYPES
+		"TrailRun":   "Other",       // Override classification.json didahulukan
+		"Walk":       "RunWalkHike",
+		"Ride":       "Bike",
+		"Yoga":       "Other",
+	} {
+		if got := classifyActivity(activityType); got != want {
+			t.Errorf("classifyActivity(%q) = %q, ingin %q", activityType, got, want)
+		}
+	}
+
+	// Jarak VirtualRun masuk kategori yang sama dengan zona pacenya
+	useDataDir(t)
+	if err := saveActivitiesFile([]map[string]interface{}{
+		{"id": 1.0, "type": "VirtualRun", "distance": 5000.0, "moving_time": 1500.0,
+			"start_date": "2024-05-02T06:00:00Z", "start_date_local": "2024-05-02T06:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := calculateLifetimeSummary(defaultStatsFilter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.RunWalkHike != 5000 || summary.Other != 0 {
+		t.Errorf("ringkasan VirtualRun: run_walk_hike = %v, other = %v, ingin 5000 dan 0", summary.RunWalkHike, summary.Other)
+	}
+	if stats := calculatePaceStats(StravaActivity{Type: "VirtualRun", Distance: 5000, MovingTime: 1500}); stats.Yellow != 5 {
+		t.Errorf("calculatePaceStats(VirtualRun) = %+v, ingin 5 km di zona kuning", stats)
+	}
+}
+
+func TestSyncVelocityStreams(t *testing.T) {
+	useDataDir(t)
+	prevLimit := streamSyncLimit
+	streamSyncLimit = 2
+	t.Cleanup(func() { streamSyncLimit = prevLimit })
+
+	if err := saveActivitiesFile([]map[string]interface{}{
+		{"id": 1.0, "type": "Run", "distance": 5000.0, "moving_time": 1500.0, "start_date": "2024-05-01T06:00:00Z"},
+		{"id": 2.0, "type": "Run", "distance": 5000.0, "moving_time": 1500.0, "start_date": "2024-05-02T06:00:00Z"},
+		{"id": 3.0, "type": "VirtualRun", "distance": 5000.0, "moving_time": 1500.0, "start_date": "2024-05-03T06:00:00Z"},
+		{"id": 4.0, "type": "Ride", "distance": 20000.0, "moving_time": 3600.0, "start_date": "2024-05-04T06:00:00Z"},
+		{"id": 5.0, "type": "Run", "distance": 5000.0, "moving_time": 1500.0, "start_date": "2024-05-05T06:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Aktivitas 5 sudah punya stream dari ekspor GPX
+	existing := activityStreams{}
+	existing.Distance.Data = []float64{0, 5000}
+	existing.VelocitySmooth.Data = []float64{3, 3}
+	if err := saveCachedVelocityStream(5, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var requested []string
+	useStravaServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("keys"); got != velocityStreamKeys {
+			t.Errorf("keys = %q, ingin %q", got, velocityStreamKeys)
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v3/activities/"), "/streams")
+		mu.Lock()
+		requested = append(requested, id)
+		mu.Unlock()
+		if id == "2" {
+			w.Write([]byte(`{}`)) // Lari di dalam ruangan tanpa stream
+			return
+		}
+		w.Write([]byte(`{"distance": {"data": [0, 2500, 5000]}, "velocity_smooth": {"data": [5, 5, 2.5]}}`))
+	}))
+
+	runSync := func() []string {
+		t.Helper()
+		mu.Lock()
+		requested = nil
+		mu.Unlock()
+		if err := syncVelocityStreams(context.Background(), "akses"); err != nil {
+			t.Fatalf("syncVelocityStreams: %v", err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requested...)
+	}
+
+	// Lari terbaru tanpa stream lebih dulu, paling banyak STREAM_SYNC_LIMIT; Ride dan aktivitas 5 dilewati
+	if got := runSync(); strings.Join(got, ",") != "3,2" {
+		t.Errorf("sinkronisasi pertama mengambil %v, ingin [3 2]", got)
+	}
+	if got := runSync(); strings.Join(got, ",") != "1" {
+		t.Errorf("sinkronisasi kedua mengambil %v, ingin [1]", got)
+	}
+	if got := runSync(); len(got) != 0 {
+		t.Errorf("sinkronisasi ketiga mengambil %v, ingin tidak ada", got)
+	}
+
+	stream, err := loadCachedVelocityStream(3)
+	if err != nil || len(stream.Velocity) != 3 {
+		t.Errorf("stream aktivitas 3 = %+v (error %v), ingin 3 sampel", stream, err)
+	}
+	if stream, err := loadCachedVelocityStream(2); err != nil || len(stream.Distance) != 0 {
+		t.Errorf("penanda stream kosong aktivitas 2 = %+v (error %v)", stream, err)
+	}
+	// Stream kosong jatuh ke kecepatan rata-rata; stream berisi dibagi per sampel
+	streamIDs := listCachedStreamIDs()
+	if got := paceStatsForActivity(StravaActivity{ID: 2, Type: "Run", Distance: 5000, MovingTime: 1500}, streamIDs); got != (PaceStat{Yellow: 5}) {
+		t.Errorf("pace aktivitas tanpa stream = %+v, ingin 5 km kuning", got)
+	}
+	if got := paceStatsForActivity(StravaActivity{ID: 3, Type: "VirtualRun", Distance: 5000, MovingTime: 1500}, streamIDs); got.Red != 2.5 || got.Green != 2.5 {
+		t.Errorf("pace aktivitas dengan stream = %+v, ingin 2.5 km merah dan 2.5 km hijau", got)
+	}
+}
+
+func TestSyncVelocityStreamsStopsOnRateLimit(t *testing.T) {
+	useDataDir(t)
+	prevLimit := streamSyncLimit
+	streamSyncLimit = 5
+	t.Cleanup(func() { streamSyncLimit = prevLimit })
+
+	if err := saveActivitiesFile([]map[string]interface{}{
+		{"id": 1.0, "type": "Run", "distance": 5000.0, "moving_time": 1500.0, "start_date": "2024-05-01T06:00:00Z"},
+		{"id": 2.0, "type": "Run", "distance": 5000.0, "moving_time": 1500.0, "start_date": "2024-05-02T06:00:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var hits atomic.Int32
+	useStravaServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("X-RateLimit-Limit", "100,1000")
+		w.Header().Set("X-RateLimit-Usage", "100,500")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+
+	err := syncVelocityStreams(context.Background(), "akses")
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		t.Errorf("error = %v, ingin *RateLimitError", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("request ke Strava = %d setelah 429, ingin 1", got)
+	}
+	if ids := listCachedStreamIDs(); len(ids) != 0 {
+		t.Errorf("stream tersimpan setelah 429: %v", ids)
+	}
+}
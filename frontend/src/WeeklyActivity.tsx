@@ -9,10 +9,10 @@ const API_BASE_URL = 'http://localhost:8080/api/weekly-pace-stats';
 // --- Tipe Data ---
 
 interface PaceStat {
-    Red: number;
-    Orange: number;
-    Yellow: number;
-    Green: number;
+    red: number;
+    orange: number;
+    yellow: number;
+    green: number;
 }
 
 interface WeeklyPaceData {
@@ -47,18 +47,18 @@ interface BarData {
 
 // Peta untuk nama tampilan (display name)
 const displayNames: Record<keyof PaceStat, string> = {
-    Red: '🔴 Maks/Interval', 
-    Orange: '🟠 Tempo/Threshold',
-    Yellow: '🟡 Steady/Aerobic',
-    Green: '🟢 Easy/Recovery',
+    red: '🔴 Maks/Interval', 
+    orange: '🟠 Tempo/Threshold',
+    yellow: '🟡 Steady/Aerobic',
+    green: '🟢 Easy/Recovery',
 };
 
 // Warna untuk setiap zona tempo (hex codes)
 const paceColors: Record<keyof PaceStat, string> = {
-    Red: '#EF4444', 
-    Orange: '#F97316', 
-    Yellow: '#FACC15', 
-    Green: '#10B981', 
+    red: '#EF4444', 
+    orange: '#F97316', 
+    yellow: '#FACC15', 
+    green: '#10B981', 
 };
 
 
@@ -105,8 +105,8 @@ const formatTime = (seconds: number): string => {
 // Menghitung total jarak dari PaceStat
 const calculateTotalDistance = (stats: PaceStat | null): number => {
     if (!stats) return 0;
-    const { Red = 0, Orange = 0, Yellow = 0, Green = 0 } = stats;
-    return Red + Orange + Yellow + Green;
+    const { red = 0, orange = 0, yellow = 0, green = 0 } = stats;
+    return red + orange + yellow + green;
 };
 
 // --- Komponen Utama ---
@@ -176,10 +176,10 @@ const App = () => {
             .sort(([dateA], [dateB]) => dateA.localeCompare(dateB))
             .map(([date, paceStats]) => {
                 const safePaceStats: PaceStat = {
-                    Red: paceStats.Red ?? 0,
-                    Orange: paceStats.Orange ?? 0,
-                    Yellow: paceStats.Yellow ?? 0,
-                    Green: paceStats.Green ?? 0,
+                    red: paceStats.red ?? 0,
+                    orange: paceStats.orange ?? 0,
+                    yellow: paceStats.yellow ?? 0,
+                    green: paceStats.green ?? 0,
                 };
 
                 const totalDistance = calculateTotalDistance(safePaceStats);